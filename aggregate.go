@@ -0,0 +1,140 @@
+package jspath
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"strconv"
+)
+
+// An Aggregation is a statistic computed by Aggregate over the values at a path.
+type Aggregation int
+
+const (
+	// Count counts the matched values, whatever their type.
+	Count Aggregation = iota
+	// Sum adds the matched numbers.
+	Sum
+	// Min is the smallest matched number.
+	Min
+	// Max is the largest matched number.
+	Max
+	// Avg is the mean of the matched numbers.
+	Avg
+)
+
+var errUnknownAggregation = errors.New("jspath: unknown aggregation")
+
+// A NumberRangeError reports a valid JSON number whose exponent is too large to
+// compute with exactly, like 1e300000000.
+type NumberRangeError struct {
+	Number string
+}
+
+func (e *NumberRangeError) Error() string {
+	return "jspath: number out of range " + strconv.Quote(e.Number)
+}
+
+// Aggregate computes the aggregations over the values at jsPath in the JSON
+// read from r, in a single pass. See StreamDecoder.Aggregate.
+func Aggregate(r io.Reader, jsPath string, aggs ...Aggregation) ([]*big.Rat, error) {
+	return NewStreamDecoder(r).Aggregate(jsPath, aggs...)
+}
+
+// Aggregate computes the aggregations over the values at jsPath and returns
+// their results in the order of aggs.
+//
+// Numbers are parsed from the raw input and computed exactly, the values that
+// are not numbers are only counted. Min, Max and Avg are nil when no number matched.
+// A number with an exponent out of range fails with a *NumberRangeError, unless
+// only Count is computed.
+func (dec *StreamDecoder) Aggregate(jsPath string, aggs ...Aggregation) ([]*big.Rat, error) {
+	a := aggregator{countOnly: true}
+	for _, agg := range aggs {
+		if agg < Count || agg > Avg {
+			return nil, errUnknownAggregation
+		}
+		if agg != Count {
+			a.countOnly = false
+		}
+	}
+	if err := dec.DecodePath(jsPath, func(key []byte, message json.RawMessage) error {
+		return a.add(message)
+	}); err != nil {
+		return nil, err
+	}
+	results := make([]*big.Rat, len(aggs))
+	for i, agg := range aggs {
+		results[i] = a.result(agg)
+	}
+	return results, nil
+}
+
+type aggregator struct {
+	count   int64
+	numbers int64
+	// countOnly skips the numbers, they are only counted.
+	countOnly bool
+
+	// integers are summed in isum as long as it does not overflow, the other numbers in sum
+	isum int64
+	sum  big.Rat
+
+	min, max big.Rat
+	n        big.Rat
+}
+
+func (a *aggregator) add(message json.RawMessage) error {
+	a.count++
+	if c := message[0]; a.countOnly || c != '-' && (c < '0' || c > '9') {
+		return nil
+	}
+	s := BytesToString(message)
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if sum := a.isum + i; (i >= 0) == (sum >= a.isum) {
+			a.isum = sum
+			a.n.SetInt64(i)
+		} else {
+			a.sum.Add(&a.sum, a.n.SetInt64(i))
+		}
+	} else if _, ok := a.n.SetString(s); ok {
+		a.sum.Add(&a.sum, &a.n)
+	} else {
+		// a valid JSON number, its exponent is out of the range of big.Rat
+		return &NumberRangeError{Number: s}
+	}
+	if a.numbers == 0 || a.n.Cmp(&a.min) < 0 {
+		a.min.Set(&a.n)
+	}
+	if a.numbers == 0 || a.n.Cmp(&a.max) > 0 {
+		a.max.Set(&a.n)
+	}
+	a.numbers++
+	return nil
+}
+
+func (a *aggregator) result(agg Aggregation) *big.Rat {
+	if agg == Count {
+		return new(big.Rat).SetInt64(a.count)
+	}
+	sum := a.total()
+	if agg == Sum {
+		return sum
+	}
+	if a.numbers == 0 {
+		return nil
+	}
+	switch agg {
+	case Min:
+		return new(big.Rat).Set(&a.min)
+	case Max:
+		return new(big.Rat).Set(&a.max)
+	}
+	return sum.Quo(sum, new(big.Rat).SetInt64(a.numbers))
+}
+
+// total returns the sum of the numbers.
+func (a *aggregator) total() *big.Rat {
+	return new(big.Rat).Add(&a.sum, new(big.Rat).SetInt64(a.isum))
+}
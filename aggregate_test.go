@@ -0,0 +1,110 @@
+package jspath
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregate(t *testing.T) {
+	var testcases = []struct {
+		name  string
+		input string
+		path  string
+		aggs  []Aggregation
+		want  []string
+	}{
+		{
+			name:  "book prices",
+			input: testdata,
+			path:  "$.store.book[*].price",
+			aggs:  []Aggregation{Count, Sum, Min, Max, Avg},
+			want:  []string{"4", "53.92", "8.95", "22.99", "13.48"},
+		},
+		{
+			name:  "count objects",
+			input: testdata,
+			path:  "$.store.book[*]",
+			aggs:  []Aggregation{Count, Sum},
+			want:  []string{"4", "0"},
+		},
+		{
+			name:  "exact decimals",
+			input: `[0.1, 0.2, 1e-1, -2.5E1]`,
+			path:  "$.[*]",
+			aggs:  []Aggregation{Sum, Min, Max},
+			want:  []string{"-24.6", "-25", "0.2"},
+		},
+		{
+			name:  "integer overflow",
+			input: `{"a": [9223372036854775807, 1, 9223372036854775807, -10]}`,
+			path:  "$.a[*]",
+			aggs:  []Aggregation{Sum, Max},
+			want:  []string{"18446744073709551605", "9223372036854775807"},
+		},
+		{
+			name:  "big numbers",
+			input: `{"a": [100000000000000000000000000000.5, -100000000000000000000000000000]}`,
+			path:  "$.a[*]",
+			aggs:  []Aggregation{Sum, Min},
+			want:  []string{"0.5", "-100000000000000000000000000000"},
+		},
+		{
+			name:  "mixed values",
+			input: `{"a": [1, "2", null, {"b": 3}, 4]}`,
+			path:  "$.a[*]",
+			aggs:  []Aggregation{Count, Sum, Avg},
+			want:  []string{"5", "5", "2.5"},
+		},
+		{
+			name:  "multiple documents",
+			input: `{"a": 1} {"a": 2} {"b": 3}`,
+			path:  "$.a",
+			aggs:  []Aggregation{Count, Sum},
+			want:  []string{"2", "3"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			results, err := Aggregate(strings.NewReader(tc.input), tc.path, tc.aggs...)
+			require.NoError(t, err)
+			got := make([]string, len(results))
+			for i, r := range results {
+				got[i] = strings.TrimRight(strings.TrimRight(r.FloatString(10), "0"), ".")
+			}
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestAggregateNoNumbers(t *testing.T) {
+	results, err := Aggregate(strings.NewReader(`{"a": "b"}`), "$.c", Count, Sum, Min, Max, Avg)
+	require.NoError(t, err)
+	require.Zero(t, results[0].Sign())
+	require.Zero(t, results[1].Sign())
+	require.Nil(t, results[2])
+	require.Nil(t, results[3])
+	require.Nil(t, results[4])
+
+	_, err = Aggregate(strings.NewReader(`{}`), "$.a", Aggregation(42))
+	require.Equal(t, errUnknownAggregation, err)
+}
+
+func TestAggregateNumberRange(t *testing.T) {
+	input := `{"a": [1, 1e300000000, -2E+400000000]}`
+	results, err := Aggregate(strings.NewReader(input), "$.a[*]", Count)
+	require.NoError(t, err)
+	require.Equal(t, "3", results[0].RatString())
+
+	for _, agg := range []Aggregation{Sum, Min, Max, Avg} {
+		_, err = Aggregate(strings.NewReader(input), "$.a[*]", Count, agg)
+		var rangeErr *NumberRangeError
+		require.ErrorAs(t, err, &rangeErr)
+		require.Equal(t, "1e300000000", rangeErr.Number)
+		var syntaxErr *SyntaxError
+		require.False(t, errors.As(err, &syntaxErr))
+	}
+}
@@ -0,0 +1,149 @@
+package jspath
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var errNotString = errors.New("not a string")
+
+// A Base64Error reports a matched value that is not a valid base64 string.
+type Base64Error struct {
+	Path string
+	// Err is the base64.CorruptInputError, or the reason the value is not a string.
+	Err error
+}
+
+func (e *Base64Error) Error() string {
+	return "jspath: " + e.Path + ": invalid base64 value: " + e.Err.Error()
+}
+
+func (e *Base64Error) Unwrap() error {
+	return e.Err
+}
+
+// DecodePathBase64 decodes the strings matching jsPath from the base64 encoding
+// enc and streams the decoded bytes to the writer returned by onPath, in constant
+// memory. No value is written when onPath returns a nil writer. Newlines in the
+// strings are ignored, as MIME encoders insert them.
+//
+// A matched value that is not a valid base64 string fails with a *Base64Error,
+// what was decoded before the failure has been written.
+func (dec *StreamDecoder) DecodePathBase64(jsPath string, enc *base64.Encoding, onPath func(key []byte) (io.Writer, error)) error {
+	u := &unescapeReader{r: bufio.NewReaderSize(nil, 512)}
+	return dec.DecodePathReader(jsPath, func(key []byte, value io.Reader) error {
+		w, err := onPath(key)
+		if err != nil || w == nil {
+			return err
+		}
+		u.reset(value)
+		_, err = io.Copy(w, base64.NewDecoder(enc, u))
+		var corrupt base64.CorruptInputError
+		if errors.As(err, &corrupt) || err == errNotString || err == io.ErrUnexpectedEOF && u.done {
+			return &Base64Error{Path: string(key), Err: err}
+		}
+		return err
+	})
+}
+
+// unescapeReader reads the content of the JSON string read from r.
+type unescapeReader struct {
+	r       *bufio.Reader
+	started bool
+	done    bool
+	// pending holds the rest of an escaped rune.
+	pending []byte
+	rune    [utf8.UTFMax]byte
+}
+
+func (u *unescapeReader) reset(r io.Reader) {
+	u.r.Reset(r)
+	u.started, u.done, u.pending = false, false, nil
+}
+
+func (u *unescapeReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(u.pending) > 0 {
+			k := copy(p[n:], u.pending)
+			u.pending = u.pending[k:]
+			n += k
+			continue
+		}
+		if u.done {
+			break
+		}
+		c, err := u.r.ReadByte()
+		if err != nil {
+			return n, err
+		}
+		if !u.started {
+			if c != '"' {
+				return n, errNotString
+			}
+			u.started = true
+			continue
+		}
+		switch c {
+		case '"':
+			u.done = true
+		case '\\':
+			r, err := u.escape()
+			if err != nil {
+				return n, err
+			}
+			u.pending = u.rune[:utf8.EncodeRune(u.rune[:], r)]
+		default:
+			p[n] = c
+			n++
+		}
+	}
+	if n == 0 && u.done {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+// escape reads the escape sequence after a backslash.
+func (u *unescapeReader) escape() (rune, error) {
+	c, err := u.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	switch c {
+	case 'b':
+		return '\b', nil
+	case 'f':
+		return '\f', nil
+	case 'n':
+		return '\n', nil
+	case 'r':
+		return '\r', nil
+	case 't':
+		return '\t', nil
+	case 'u':
+	default:
+		return rune(c), nil
+	}
+	digits, err := u.r.Peek(4)
+	if err != nil {
+		return 0, err
+	}
+	r := hexRune(digits)
+	u.r.Discard(4)
+	if !utf16.IsSurrogate(r) {
+		return r, nil
+	}
+	// the low half of a surrogate pair
+	if low, err := u.r.Peek(6); err == nil && low[0] == '\\' && low[1] == 'u' {
+		if r = utf16.DecodeRune(r, hexRune(low[2:])); r != utf8.RuneError {
+			u.r.Discard(6)
+		}
+		return r, nil
+	}
+	return utf8.RuneError, nil
+}
@@ -0,0 +1,93 @@
+package jspath
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodePathBase64(t *testing.T) {
+	file := bytes.Repeat([]byte("binary\x00\xff payload "), 50000)
+	encoded := base64.StdEncoding.EncodeToString(file)
+	// MIME line breaks and an escaped slash
+	wrapped := strings.Replace(encoded[:76]+`\r\n`+encoded[76:], "/", `\/`, 1)
+	input := `{"files": [{"name": "a.bin", "data": "` + wrapped + `"}, {"name": "b.txt", "data": "aGVsbG8="}, {"name": "c", "data": "aGU="}]}`
+
+	var got = map[string][]byte{}
+	dec := NewStreamDecoder(iotest.HalfReader(strings.NewReader(input)))
+	require.NoError(t, dec.DecodePathBase64("$.files[*].data", base64.StdEncoding, func(key []byte) (io.Writer, error) {
+		name := string(key)
+		return writerFunc(func(p []byte) (int, error) {
+			got[name] = append(got[name], p...)
+			return len(p), nil
+		}), nil
+	}))
+	require.Equal(t, sha256.Sum256(file), sha256.Sum256(got["$.files[0].data"]))
+	require.Equal(t, "hello", string(got["$.files[1].data"]))
+	require.Equal(t, "he", string(got["$.files[2].data"]))
+	require.Less(t, cap(dec.buf), 1<<16)
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
+
+func TestDecodePathBase64Skip(t *testing.T) {
+	var got []string
+	input := `{"a": "aGVsbG8=", "b": "d29ybGQ="}`
+	require.NoError(t, NewStreamDecoder(strings.NewReader(input)).DecodePathBase64("$.*", base64.StdEncoding, func(key []byte) (io.Writer, error) {
+		if string(key) == "$.a" {
+			return nil, nil
+		}
+		return writerFunc(func(p []byte) (int, error) {
+			got = append(got, string(key)+" "+string(p))
+			return len(p), nil
+		}), nil
+	}))
+	require.Equal(t, []string{"$.b world"}, got)
+}
+
+func TestDecodePathBase64Errors(t *testing.T) {
+	var testcases = []struct {
+		name  string
+		input string
+		path  string
+	}{
+		{name: "invalid character", input: `{"a": "aGVs*G8="}`, path: "$.a"},
+		{name: "truncated", input: `{"a": "aGVsbG8"}`, path: "$.a"},
+		{name: "not a string", input: `{"a": [1]}`, path: "$.a"},
+		{name: "number", input: `[1]`, path: "$.[0]"},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := NewStreamDecoder(strings.NewReader(tc.input)).DecodePathBase64("$.**", base64.StdEncoding, func(key []byte) (io.Writer, error) {
+				return io.Discard, nil
+			})
+			var berr *Base64Error
+			require.True(t, errors.As(err, &berr), "%v", err)
+			require.Equal(t, tc.path, berr.Path)
+		})
+	}
+
+	errWrite := errors.New("disk full")
+	err := NewStreamDecoder(strings.NewReader(`{"a": "aGVsbG8="}`)).DecodePathBase64("$.a", base64.StdEncoding, func(key []byte) (io.Writer, error) {
+		return writerFunc(func(p []byte) (int, error) {
+			return 0, errWrite
+		}), nil
+	})
+	require.Equal(t, errWrite, err)
+
+	err = NewStreamDecoder(strings.NewReader(`{"a": "aGVsbG8=`)).DecodePathBase64("$.a", base64.StdEncoding, func(key []byte) (io.Writer, error) {
+		return io.Discard, nil
+	})
+	require.Equal(t, io.ErrUnexpectedEOF, err)
+}
@@ -0,0 +1,696 @@
+package jspath
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"unicode/utf8"
+)
+
+var (
+	// errBinaryShort is returned by binaryFormat.item when the header is incomplete.
+	errBinaryShort       = errors.New("jspath: short binary item")
+	errBinaryUnsupported = errors.New("jspath: checkpoints and observers are not supported for binary input")
+)
+
+const (
+	binaryScalar = iota
+	binaryString
+	binaryBytes
+	binaryMap
+	binaryArray
+	// binaryTag annotates the item that follows it.
+	binaryTag
+	// binaryBreak ends an indefinite length container.
+	binaryBreak
+)
+
+// A binaryItem is the header of an item of a binary encoding.
+type binaryItem struct {
+	kind int
+	// head is the size of the header, scalars included.
+	head int
+	// length is the size of the payload of strings, bytes and extensions,
+	// the number of entries of maps and arrays, -1 for an indefinite length.
+	length int
+}
+
+// A binaryFormat is a binary encoding of the JSON data model.
+type binaryFormat interface {
+	// item parses the header of the item data starts with,
+	// it returns errBinaryShort when data does not hold the whole header.
+	item(data []byte) (binaryItem, error)
+	// appendScalar appends the JSON encoding of the whole scalar item data.
+	appendScalar(dst []byte, data []byte) ([]byte, error)
+}
+
+// A binaryTagFormat converts some tagged byte strings itself.
+type binaryTagFormat interface {
+	// appendTagged appends the JSON encoding of the content tagged by the tag item,
+	// ok is false when the tag has no conversion.
+	appendTagged(dst []byte, tag []byte, content []byte) (out []byte, ok bool, err error)
+}
+
+type binaryFrame struct {
+	kind int
+	// remaining is the number of entries left, -1 for an indefinite length.
+	remaining int
+	// key is set while a map expects a key.
+	key bool
+}
+
+// decodeBinary is the decode loop of the binary formats, it drives the path
+// and the matchers like the JSON one.
+func (dec *StreamDecoder) decodeBinary(decoders []decoder) error {
+	if dec.onCheckpoint != nil || dec.observer != nil {
+		return errBinaryUnsupported
+	}
+	filtered := false
+	for _, d := range decoders {
+		filtered = filtered || d.kinds != 0
+	}
+	var frames []binaryFrame
+	for {
+		select {
+		case <-dec.context.Done():
+			return dec.context.Err()
+		default:
+		}
+		if len(frames) == 0 {
+			if err := dec.ensure(1); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+		}
+		it, err := dec.binaryItem(0)
+		if err != nil {
+			return err
+		}
+		var top *binaryFrame
+		if len(frames) > 0 {
+			top = &frames[len(frames)-1]
+		}
+		if it.kind == binaryBreak {
+			if top == nil || top.remaining >= 0 {
+				return &SyntaxError{msg: "unexpected break", Offset: dec.offset()}
+			}
+			dec.scanp += it.head
+			frames = dec.binaryEnd(frames)
+			frames = dec.binaryValueEnd(frames)
+			continue
+		}
+		if top != nil && top.key {
+			if err := dec.binaryKey(it); err != nil {
+				return err
+			}
+			top.key = false
+			continue
+		}
+
+		kind := Invalid
+		if filtered {
+			if kind, err = dec.binaryKind(it); err != nil {
+				return err
+			}
+		}
+		curPath := dec.path.PathBytes()
+		if match, itemDecoder := matcher(decoders).match(BytesToString(curPath), kind); match {
+			dec.captures = itemDecoder.captured()
+			n, err := dec.binaryValueLen()
+			if err != nil {
+				return err
+			}
+			value := dec.buf[dec.scanp : dec.scanp+n]
+			if err := dec.checkBinary(curPath, value); err != nil {
+				return err
+			}
+			if !dec.rawBinary {
+				if dec.transcoded, err = transcodeBinary(dec.format, dec.transcoded[0:0], value); err != nil {
+					return err
+				}
+				value = dec.transcoded
+			}
+			if mu, ok := itemDecoder.unmarshaler.(MatchUnmarshalerStream); ok {
+				if err := dec.binaryMatch(mu, it, n, value); err != nil {
+					return err
+				}
+			} else if err := itemDecoder.unmarshaler.UnmarshalStream(curPath, value); err != nil {
+				return err
+			}
+			dec.scanp += n
+			frames = dec.binaryValueEnd(frames)
+			continue
+		}
+
+		switch it.kind {
+		case binaryTag:
+			// the tagged item has the same path
+			dec.scanp += it.head
+		case binaryMap, binaryArray:
+			delim := byte('[')
+			if it.kind == binaryMap {
+				delim = '{'
+			}
+			if err := dec.checkOpen(delim, curPath, dec.offset()); err != nil {
+				return err
+			}
+			dec.scanp += it.head
+			if it.length == 0 {
+				dec.checkClose(delim)
+				frames = dec.binaryValueEnd(frames)
+				continue
+			}
+			frames = append(frames, binaryFrame{kind: it.kind, remaining: it.length, key: it.kind == binaryMap})
+			if it.kind == binaryMap {
+				dec.path.StartObject()
+			} else {
+				dec.path.StartArray()
+			}
+		default:
+			n, err := dec.binaryValueLen()
+			if err != nil {
+				return err
+			}
+			if err := dec.checkBinary(curPath, dec.buf[dec.scanp:dec.scanp+n]); err != nil {
+				return err
+			}
+			dec.scanp += n
+			frames = dec.binaryValueEnd(frames)
+		}
+	}
+}
+
+// binaryKind returns the kind of the JSON encoding of the value starting with it.
+func (dec *StreamDecoder) binaryKind(it binaryItem) (Kind, error) {
+	switch it.kind {
+	case binaryMap:
+		return Object, nil
+	case binaryArray:
+		return Array, nil
+	case binaryString, binaryBytes:
+		return String, nil
+	}
+	n, err := dec.binaryValueLen()
+	if err != nil {
+		return Invalid, err
+	}
+	if dec.transcoded, err = transcodeBinary(dec.format, dec.transcoded[0:0], dec.buf[dec.scanp:dec.scanp+n]); err != nil {
+		return Invalid, err
+	}
+	return literalKind(dec.transcoded[0]), nil
+}
+
+// binaryMatch hands the matched value of n bytes starting with it to mu.
+func (dec *StreamDecoder) binaryMatch(mu MatchUnmarshalerStream, it binaryItem, n int, value []byte) error {
+	kind := literalKind(value[0])
+	if dec.rawBinary {
+		var err error
+		if kind, err = dec.binaryKind(it); err != nil {
+			return err
+		}
+	}
+	dec.match = Match{Kind: kind, Offset: dec.offset(), Length: n, Captures: dec.captures}
+	dec.path.match(&dec.match)
+	return mu.UnmarshalMatch(&dec.match, value)
+}
+
+// binaryKey reads the map key it and sets it as the current path key.
+// Keys that are not strings are rendered as JSON.
+func (dec *StreamDecoder) binaryKey(it binaryItem) error {
+	n, err := dec.binaryValueLen()
+	if err != nil {
+		return err
+	}
+	data := dec.buf[dec.scanp : dec.scanp+n]
+	switch it.kind {
+	case binaryMap, binaryArray:
+		return &SyntaxError{msg: "unsupported container map key", Offset: dec.offset()}
+	case binaryString:
+		if it.length >= 0 {
+			if len(dec.checkers) > 0 {
+				dec.transcoded = appendQuoted(dec.transcoded[0:0], data[it.head:], false)
+				if err := dec.checkMember(dec.transcoded, dec.offset()); err != nil {
+					return err
+				}
+			}
+			dec.path.SetObjectKey(data[it.head:])
+			break
+		}
+		fallthrough
+	default:
+		key, err := transcodeBinary(dec.format, dec.transcoded[0:0], data)
+		if err != nil {
+			return err
+		}
+		dec.transcoded = key
+		if len(dec.checkers) > 0 {
+			if key[0] != '"' {
+				dec.key = appendJSONString(dec.key[0:0], key)
+				key = dec.key
+			}
+			if err := dec.checkMember(key, dec.offset()); err != nil {
+				return err
+			}
+		}
+		if key[0] == '"' {
+			dec.key = appendUnescaped(dec.key[0:0], key[1:len(key)-1])
+			key = dec.key
+		}
+		dec.path.SetObjectKey(key)
+	}
+	dec.scanp += n
+	return nil
+}
+
+// binaryValueEnd accounts for a value read in the innermost frame,
+// the frames it completes are closed.
+func (dec *StreamDecoder) binaryValueEnd(frames []binaryFrame) []binaryFrame {
+	for len(frames) > 0 {
+		top := &frames[len(frames)-1]
+		if top.kind == binaryMap {
+			top.key = true
+		}
+		if top.remaining > 0 {
+			top.remaining--
+		}
+		if top.remaining == 0 {
+			frames = dec.binaryEnd(frames)
+			continue
+		}
+		if top.kind == binaryArray {
+			dec.path.IncrementArrayIndex()
+		}
+		break
+	}
+	return frames
+}
+
+func (dec *StreamDecoder) binaryEnd(frames []binaryFrame) []binaryFrame {
+	if frames[len(frames)-1].kind == binaryMap {
+		dec.path.EndObject()
+		dec.checkClose('{')
+	} else {
+		dec.path.EndArray()
+		dec.checkClose('[')
+	}
+	return frames[:len(frames)-1]
+}
+
+// ensure makes n bytes available after dec.scanp. It returns io.EOF when the
+// input is exhausted before the first of them, io.ErrUnexpectedEOF after.
+func (dec *StreamDecoder) ensure(n int) error {
+	if err := dec.checkValueSize(n); err != nil {
+		return err
+	}
+	for len(dec.buf)-dec.scanp < n {
+		err := dec.refill()
+		if len(dec.buf)-dec.scanp >= n {
+			return nil
+		}
+		if err == io.EOF && len(dec.buf) > dec.scanp {
+			return io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// binaryItem parses the header of the item at pos bytes after dec.scanp.
+func (dec *StreamDecoder) binaryItem(pos int) (binaryItem, error) {
+	for {
+		it, err := dec.format.item(dec.buf[dec.scanp+pos:])
+		if err != errBinaryShort {
+			if err != nil {
+				return it, &SyntaxError{msg: err.Error(), Offset: dec.offset() + int64(pos)}
+			}
+			return it, nil
+		}
+		if err := dec.ensure(len(dec.buf) - dec.scanp + 1); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return it, err
+		}
+	}
+}
+
+// binaryValueLen reads the whole value at dec.scanp into the buffer and returns its size.
+func (dec *StreamDecoder) binaryValueLen() (int, error) {
+	pos := 0
+	counts := []int{1}
+	for len(counts) > 0 {
+		it, err := dec.binaryItem(pos)
+		if err != nil {
+			return 0, err
+		}
+		pos += it.head
+		switch it.kind {
+		case binaryBreak:
+			if counts[len(counts)-1] >= 0 {
+				return 0, &SyntaxError{msg: "unexpected break", Offset: dec.offset() + int64(pos)}
+			}
+			counts = binaryItemDone(counts[:len(counts)-1])
+			continue
+		case binaryTag:
+			continue
+		case binaryMap, binaryArray:
+			n := it.length
+			if it.kind == binaryMap && n > 0 {
+				n *= 2
+			}
+			if n != 0 {
+				counts = append(counts, n)
+				continue
+			}
+		default:
+			if it.length < 0 {
+				// indefinite length strings are made of chunks
+				counts = append(counts, -1)
+				continue
+			}
+			pos += it.length
+			if err := dec.ensure(pos); err != nil {
+				return 0, err
+			}
+		}
+		counts = binaryItemDone(counts)
+	}
+	return pos, nil
+}
+
+// binaryItemDone accounts for an item read at the innermost level of counts.
+func binaryItemDone(counts []int) []int {
+	for len(counts) > 0 {
+		top := len(counts) - 1
+		if counts[top] < 0 {
+			return counts
+		}
+		if counts[top]--; counts[top] > 0 {
+			return counts
+		}
+		counts = counts[:top]
+	}
+	return counts
+}
+
+// transcodeBinary appends the JSON encoding of the whole binary value data to dst.
+func transcodeBinary(f binaryFormat, dst []byte, data []byte) ([]byte, error) {
+	dst, _, err := (&binaryTranscoder{format: f, data: data}).value(dst, 0)
+	return dst, err
+}
+
+type binaryTranscoder struct {
+	format binaryFormat
+	data   []byte
+}
+
+func (t *binaryTranscoder) item(pos int) (binaryItem, error) {
+	it, err := t.format.item(t.data[pos:])
+	if err != nil {
+		return it, err
+	}
+	end := pos + it.head
+	if it.kind != binaryMap && it.kind != binaryArray && it.length > 0 {
+		end += it.length
+	}
+	if end > len(t.data) {
+		return it, errBinaryShort
+	}
+	return it, nil
+}
+
+// value appends the JSON encoding of the item at pos and returns the position after it.
+func (t *binaryTranscoder) value(dst []byte, pos int) ([]byte, int, error) {
+	it, err := t.item(pos)
+	if err != nil {
+		return nil, 0, err
+	}
+	start := pos
+	pos += it.head
+	switch it.kind {
+	case binaryTag:
+		if f, ok := t.format.(binaryTagFormat); ok {
+			tagged, err := t.item(pos)
+			if err != nil {
+				return nil, 0, err
+			}
+			if tagged.kind == binaryBytes {
+				content, end, err := t.content(tagged, pos+tagged.head)
+				if err != nil {
+					return nil, 0, err
+				}
+				out, ok, err := f.appendTagged(dst, t.data[start:pos], content)
+				if err != nil || ok {
+					return out, end, err
+				}
+			}
+		}
+		return t.value(dst, pos)
+	case binaryBreak:
+		return nil, 0, errors.New("jspath: unexpected break")
+	case binaryMap, binaryArray:
+		open, close := byte('['), byte(']')
+		if it.kind == binaryMap {
+			open, close = '{', '}'
+		}
+		dst = append(dst, open)
+		for i := 0; it.length < 0 || i < it.length; i++ {
+			if it.length < 0 {
+				end, err := t.item(pos)
+				if err != nil {
+					return nil, 0, err
+				}
+				if end.kind == binaryBreak {
+					pos += end.head
+					break
+				}
+			}
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			if it.kind == binaryMap {
+				if dst, pos, err = t.key(dst, pos); err != nil {
+					return nil, 0, err
+				}
+				dst = append(dst, ':')
+			}
+			if dst, pos, err = t.value(dst, pos); err != nil {
+				return nil, 0, err
+			}
+		}
+		return append(dst, close), pos, nil
+	case binaryString, binaryBytes:
+		var content []byte
+		if content, pos, err = t.content(it, pos); err != nil {
+			return nil, 0, err
+		}
+		if it.kind == binaryBytes {
+			dst = append(dst, '"')
+			dst = append(dst, base64.StdEncoding.EncodeToString(content)...)
+			return append(dst, '"'), pos, nil
+		}
+		return appendJSONString(dst, content), pos, nil
+	}
+	pos += it.length
+	dst, err = t.format.appendScalar(dst, t.data[start:pos])
+	return dst, pos, err
+}
+
+// content returns the content of the string or bytes item it, joining the chunks of indefinite lengths.
+func (t *binaryTranscoder) content(it binaryItem, pos int) ([]byte, int, error) {
+	if it.length >= 0 {
+		return t.data[pos : pos+it.length], pos + it.length, nil
+	}
+	var content []byte
+	for {
+		chunk, err := t.item(pos)
+		if err != nil {
+			return nil, 0, err
+		}
+		pos += chunk.head
+		if chunk.kind == binaryBreak {
+			return content, pos, nil
+		}
+		if chunk.kind != it.kind || chunk.length < 0 {
+			return nil, 0, errors.New("jspath: invalid indefinite length chunk")
+		}
+		content = append(content, t.data[pos:pos+chunk.length]...)
+		pos += chunk.length
+	}
+}
+
+// key appends the map key at pos as a JSON string, keys that are not strings are quoted.
+func (t *binaryTranscoder) key(dst []byte, pos int) ([]byte, int, error) {
+	it, err := t.item(pos)
+	if err != nil {
+		return nil, 0, err
+	}
+	if it.kind == binaryString {
+		return t.value(dst, pos)
+	}
+	if it.kind == binaryMap || it.kind == binaryArray {
+		return nil, 0, errors.New("jspath: unsupported container map key")
+	}
+	start := len(dst)
+	if dst, pos, err = t.value(dst, pos); err != nil {
+		return nil, 0, err
+	}
+	if dst[start] == '"' {
+		return dst, pos, nil
+	}
+	key := string(dst[start:])
+	return appendJSONString(dst[:start], []byte(key)), pos, nil
+}
+
+// checkBinary walks the binary value just read at path with the checkers.
+func (dec *StreamDecoder) checkBinary(path []byte, value []byte) error {
+	if len(dec.checkers) == 0 {
+		return nil
+	}
+	t := &binaryTranscoder{format: dec.format, data: value}
+	for _, c := range dec.checkers {
+		dec.checkPath = append(dec.checkPath[0:0], path...)
+		if _, err := t.check(c, dec.checkPath, 0, dec.offset()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// check walks the item at pos, at path, like walkValue the JSON values, and
+// returns the position after it. Strings are quoted as is, keeping invalid UTF-8.
+// offset is the input offset of the data.
+func (t *binaryTranscoder) check(c valueChecker, path []byte, pos int, offset int64) (int, error) {
+	it, err := t.item(pos)
+	if err != nil {
+		return 0, err
+	}
+	start := pos
+	pos += it.head
+	switch it.kind {
+	case binaryTag:
+		return t.check(c, path, pos, offset)
+	case binaryString:
+		content, end, err := t.content(it, pos)
+		if err != nil {
+			return 0, err
+		}
+		return end, c.str(path, appendQuoted(nil, content, false), offset+int64(start))
+	case binaryBytes:
+		_, end, err := t.content(it, pos)
+		return end, err
+	case binaryMap, binaryArray:
+		delim := byte('[')
+		if it.kind == binaryMap {
+			delim = '{'
+		}
+		if err := c.open(delim, path, offset+int64(start)); err != nil {
+			return 0, err
+		}
+		defer c.close(delim)
+		if delim == '[' && len(path) == 1 {
+			path = append(path, '.')
+		}
+		for i := 0; it.length < 0 || i < it.length; i++ {
+			if it.length < 0 {
+				end, err := t.item(pos)
+				if err != nil {
+					return 0, err
+				}
+				if end.kind == binaryBreak {
+					return pos + end.head, nil
+				}
+			}
+			var child []byte
+			if delim == '{' {
+				quoted, end, err := t.checkKey(pos)
+				if err != nil {
+					return 0, err
+				}
+				child = appendUnescaped(append(path, '.'), quoted[1:len(quoted)-1])
+				if err := c.member(child, quoted, offset+int64(pos)); err != nil {
+					return 0, err
+				}
+				pos = end
+			} else {
+				child = append(strconv.AppendInt(append(path, '['), int64(i), 10), ']')
+			}
+			if pos, err = t.check(c, child, pos, offset); err != nil {
+				return 0, err
+			}
+		}
+		return pos, nil
+	}
+	return pos + it.length, nil
+}
+
+// checkKey returns the map key at pos quoted for the checkers, and the position after it.
+func (t *binaryTranscoder) checkKey(pos int) ([]byte, int, error) {
+	it, err := t.item(pos)
+	if err != nil {
+		return nil, 0, err
+	}
+	if it.kind == binaryString {
+		content, end, err := t.content(it, pos+it.head)
+		if err != nil {
+			return nil, 0, err
+		}
+		return appendQuoted(nil, content, false), end, nil
+	}
+	return t.key(nil, pos)
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString appends s as a JSON string, invalid UTF-8 is replaced by U+FFFD.
+func appendJSONString(dst []byte, s []byte) []byte {
+	return appendQuoted(dst, s, true)
+}
+
+// appendQuoted appends s as a JSON string, invalid UTF-8 is replaced by U+FFFD
+// when replace is set, it is kept for the checkers otherwise.
+func appendQuoted(dst []byte, s []byte, replace bool) []byte {
+	dst = append(dst, '"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				dst = append(dst, '\\', c)
+			case c == '\n':
+				dst = append(dst, '\\', 'n')
+			case c == '\r':
+				dst = append(dst, '\\', 'r')
+			case c == '\t':
+				dst = append(dst, '\\', 't')
+			case c < 0x20:
+				dst = append(dst, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xf])
+			default:
+				dst = append(dst, c)
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRune(s[i:])
+		if r == utf8.RuneError && size == 1 && replace {
+			dst = append(dst, "\ufffd"...)
+		} else {
+			dst = append(dst, s[i:i+size]...)
+		}
+		i += size
+	}
+	return append(dst, '"')
+}
+
+// appendJSONFloat appends f as a JSON number, NaN and infinities have no JSON encoding.
+func appendJSONFloat(dst []byte, f float64, bitSize int) ([]byte, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, errors.New("jspath: " + strconv.FormatFloat(f, 'g', -1, 64) + " cannot be represented in JSON")
+	}
+	return strconv.AppendFloat(dst, f, 'g', -1, bitSize), nil
+}
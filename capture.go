@@ -0,0 +1,237 @@
+package jspath
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gobwas/glob"
+)
+
+// A Capture is the segment of a matched path captured by a named wildcard.
+type Capture struct {
+	Name string
+	Segment
+}
+
+// Captures holds the segments captured by the named wildcards of a path, in the
+// order of the path. Paths name their wildcards with braces,
+//
+//	$.tenants.{tenant}.config
+//	$.users[{u}].orders[{o}]
+//
+// capture a member name and array indices. In these paths every segment is
+// matched on its own: a * matches within a single member name and [*] any index.
+// The captures are handed to the DecodeCaptures handlers and in Match.Captures,
+// the other handlers get them from StreamDecoder.Captures. The captured names
+// are only valid until the handler returns.
+type Captures []Capture
+
+// Index returns the array index captured by name, -1 when it captured a member
+// name or is missing.
+func (c Captures) Index(name string) int {
+	for i := range c {
+		if c[i].Name == name {
+			return c[i].Index
+		}
+	}
+	return -1
+}
+
+// Member returns the member name captured by name, nil when it captured an array
+// index or is missing.
+func (c Captures) Member(name string) []byte {
+	for i := range c {
+		if c[i].Name == name {
+			return c[i].Segment.Name
+		}
+	}
+	return nil
+}
+
+// Map returns the captures as strings, the indices formatted in decimal.
+func (c Captures) Map() map[string]string {
+	m := make(map[string]string, len(c))
+	for _, capture := range c {
+		if capture.Segment.Name == nil {
+			m[capture.Name] = strconv.Itoa(capture.Index)
+			continue
+		}
+		m[capture.Name] = string(capture.Segment.Name)
+	}
+	return m
+}
+
+// Captures returns the segments captured by the path matching the value being
+// handled, nil for paths without named wildcards. It is meant to be called from
+// the handlers, the captures are only valid until they return.
+func (dec *StreamDecoder) Captures() Captures {
+	return dec.captures
+}
+
+// DecodeCaptures is like DecodePath, with the segments captured by the named
+// wildcards of jsPath:
+//
+//	dec.DecodeCaptures("$.tenants.{tenant}.config", func(key []byte, c jspath.Captures, message json.RawMessage) error {
+//		return load(string(c.Member("tenant")), message)
+//	})
+func (dec *StreamDecoder) DecodeCaptures(jsPath string, onCapture func(key []byte, captures Captures, message json.RawMessage) error) error {
+	return dec.DecodePath(jsPath, func(key []byte, message json.RawMessage) error {
+		return onCapture(key, dec.captures, message)
+	})
+}
+
+// captureStep matches one segment of a path with named wildcards.
+type captureStep struct {
+	// capture is the name of the wildcard, empty for the other steps.
+	capture string
+	array   bool
+	// index is the array index matched, -1 for any.
+	index int
+	// name is the member name matched, or glob matches it when set.
+	name string
+	glob glob.Glob
+	any  bool
+}
+
+// capturePattern matches the locations of a path with named wildcards, values
+// holds the captures of the last match.
+type capturePattern struct {
+	steps  []captureStep
+	values Captures
+}
+
+// hasCaptures reports whether jsPath has named wildcards, {name} made of
+// letters, digits and underscores. Glob alternatives like {a,b} are not.
+func hasCaptures(jsPath string) bool {
+	for i := strings.IndexByte(jsPath, '{'); i >= 0; i = strings.IndexByte(jsPath, '{') {
+		jsPath = jsPath[i+1:]
+		end := strings.IndexByte(jsPath, '}')
+		if end > 0 && isCaptureName(jsPath[:end]) {
+			return true
+		}
+	}
+	return false
+}
+
+func isCaptureName(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+			return false
+		}
+	}
+	return len(s) > 0
+}
+
+func compileCaptures(jsPath string) (*capturePattern, error) {
+	if !strings.HasPrefix(jsPath, "$") {
+		return nil, fmt.Errorf("jspath: %s: path must start with $", jsPath)
+	}
+	p := &capturePattern{}
+	seen := map[string]bool{}
+	for s := jsPath[1:]; len(s) > 0; {
+		step := captureStep{index: -1}
+		if strings.HasPrefix(s, ".[") {
+			// the top-level arrays are rendered as $.[0]
+			s = s[1:]
+		}
+		switch s[0] {
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("jspath: %s: unterminated index", jsPath)
+			}
+			step.array = true
+			switch index := s[1:end]; {
+			case index == "*":
+			case len(index) > 2 && index[0] == '{' && index[len(index)-1] == '}' && isCaptureName(index[1:len(index)-1]):
+				step.capture = index[1 : len(index)-1]
+			default:
+				i, err := strconv.Atoi(index)
+				if err != nil || i < 0 {
+					return nil, fmt.Errorf("jspath: %s: invalid index %q", jsPath, index)
+				}
+				step.index = i
+			}
+			s = s[end+1:]
+		case '.':
+			end := strings.IndexAny(s[1:], ".[") + 1
+			if end == 0 {
+				end = len(s)
+			}
+			switch name := s[1:end]; {
+			case name == "*":
+				step.any = true
+			case len(name) > 2 && name[0] == '{' && name[len(name)-1] == '}' && isCaptureName(name[1:len(name)-1]):
+				step.capture = name[1 : len(name)-1]
+			case strings.ContainsAny(name, "*{"):
+				g, err := glob.Compile(name)
+				if err != nil {
+					return nil, err
+				}
+				step.glob = g
+			default:
+				step.name = unescapePath(name)
+			}
+			s = s[end:]
+		default:
+			return nil, fmt.Errorf("jspath: %s: unexpected %q", jsPath, s[0])
+		}
+		if step.capture != "" {
+			if seen[step.capture] {
+				return nil, fmt.Errorf("jspath: %s: duplicate capture {%s}", jsPath, step.capture)
+			}
+			seen[step.capture] = true
+		}
+		p.steps = append(p.steps, step)
+	}
+	return p, nil
+}
+
+// match reports whether l matches the pattern, and sets the captures.
+func (p *capturePattern) match(l Location) bool {
+	p.values = p.values[0:0]
+	if len(l) != len(p.steps) {
+		return false
+	}
+	for i, step := range p.steps {
+		segment := l[i]
+		if step.array != (segment.Name == nil) {
+			return false
+		}
+		switch {
+		case step.capture != "":
+			p.values = append(p.values, Capture{Name: step.capture, Segment: segment})
+		case step.array:
+			if step.index >= 0 && step.index != segment.Index {
+				return false
+			}
+		case step.glob != nil:
+			if !step.glob.Match(BytesToString(segment.Name)) {
+				return false
+			}
+		case !step.any:
+			if BytesToString(segment.Name) != step.name {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// captureMatcher returns the matcher of the paths of dec matching p.
+func (dec *StreamDecoder) captureMatcher(p *capturePattern) func(curPath, jsPath string) bool {
+	return func(curPath, jsPath string) bool {
+		return p.match(dec.path.locationAt(len(curPath)))
+	}
+}
+
+// captured returns the captures of the last match of d, nil without named wildcards.
+func (d decoder) captured() Captures {
+	if d.captures == nil {
+		return nil
+	}
+	return d.captures.values
+}
@@ -0,0 +1,197 @@
+package jspath
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodePathCaptures(t *testing.T) {
+	var testcases = []struct {
+		name  string
+		path  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "indices",
+			path:  "$.users[{u}].orders[{o}]",
+			input: `{"users": [{"orders": [10, 11]}, {"id": 2}, {"orders": [12]}]}`,
+			want: []string{
+				`map[o:0 u:0] 10`,
+				`map[o:1 u:0] 11`,
+				`map[o:0 u:2] 12`,
+			},
+		},
+		{
+			name:  "member names",
+			path:  "$.tenants.{tenant}.config",
+			input: `{"tenants": {"acme": {"config": 1}, "a.b[0]": {"config": 2}, "c": {"other": {"config": 3}}}}`,
+			want: []string{
+				`map[tenant:acme] 1`,
+				`map[tenant:a.b[0]] 2`,
+			},
+		},
+		{
+			name:  "wildcards within a segment",
+			path:  "$.{k}[*].id_*",
+			input: `{"a": [{"id_1": 1, "name": "x"}, {"id_2": 2}], "b": [[{"id_3": 3}]], "c": {"d": [{"id_4": 4}]}}`,
+			want: []string{
+				`map[k:a] 1`,
+				`map[k:a] 2`,
+			},
+		},
+		{
+			name:  "literal segments",
+			path:  "$.a[1].{k}",
+			input: `{"a": [{"x": 0}, {"y": 1, "z": [2]}]}`,
+			want: []string{
+				`map[k:y] 1`,
+				`map[k:z] [2]`,
+			},
+		},
+		{
+			name:  "top level arrays",
+			path:  "$.[{i}]",
+			input: `[1, {"a": 2}] [3]`,
+			want: []string{
+				`map[i:0] 1`,
+				`map[i:1] {"a": 2}`,
+				`map[i:0] 3`,
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			dec := NewStreamDecoder(strings.NewReader(tc.input))
+			require.NoError(t, dec.DecodePath(tc.path, func(key []byte, message json.RawMessage) error {
+				got = append(got, fmt.Sprint(dec.Captures().Map())+" "+string(message))
+				return nil
+			}))
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestDecodeCaptures(t *testing.T) {
+	var got []string
+	input := `{"users": [{"name": "a", "id": 1}, {"name": "b"}]}`
+	require.NoError(t, NewStreamDecoder(strings.NewReader(input)).DecodeCaptures("$.users[{u}].{field}", func(key []byte, c Captures, message json.RawMessage) error {
+		got = append(got, fmt.Sprintf("%s %d %s %s", key, c.Index("u"), c.Member("field"), message))
+		return nil
+	}))
+	require.Equal(t, []string{`$.users[0].name 0 name "a"`, `$.users[0].id 0 id 1`, `$.users[1].name 1 name "b"`}, got)
+
+	// paths without named wildcards capture nothing
+	got = got[0:0]
+	dec := NewStreamDecoder(strings.NewReader(input))
+	require.NoError(t, dec.DecodeCaptures("$.users[*].name", func(key []byte, c Captures, message json.RawMessage) error {
+		require.Nil(t, c)
+		require.Nil(t, dec.Captures())
+		got = append(got, string(message))
+		return nil
+	}))
+	require.Equal(t, []string{`"a"`, `"b"`}, got)
+}
+
+func TestCapturesAccessors(t *testing.T) {
+	var got []string
+	input := `{"users": [{"orders": {"o1": true}}, {"orders": {"o2": false}}]}`
+	require.NoError(t, NewStreamDecoder(strings.NewReader(input)).DecodeMatch("$.users[{u}].orders.{order}", func(m *Match, message json.RawMessage) error {
+		c := m.Captures
+		require.Nil(t, c.Member("u"))
+		require.Equal(t, -1, c.Index("order"))
+		require.Equal(t, -1, c.Index("missing"))
+		require.Nil(t, c.Member("missing"))
+		got = append(got, fmt.Sprintf("%d %s %s", c.Index("u"), c.Member("order"), message))
+		return nil
+	}))
+	require.Equal(t, []string{"0 o1 true", "1 o2 false"}, got)
+}
+
+func TestCapturesHandlers(t *testing.T) {
+	var got []string
+	input := `{"a": ["x", "y"], "b": 1}`
+	dec := NewStreamDecoder(strings.NewReader(input))
+	require.NoError(t, dec.Decode(
+		NewRawStreamUnmarshaler("$.b", func(key []byte, message json.RawMessage) error {
+			require.Nil(t, dec.Captures())
+			got = append(got, string(key))
+			return nil
+		}),
+		NewRawStreamUnmarshaler("$.{k}[{i}]", func(key []byte, message json.RawMessage) error {
+			got = append(got, fmt.Sprint(dec.Captures().Map()))
+			return nil
+		}),
+	))
+	require.Equal(t, []string{"map[i:0 k:a]", "map[i:1 k:a]", "$.b"}, got)
+
+	got = got[0:0]
+	dec = NewStreamDecoder(strings.NewReader(input))
+	require.NoError(t, dec.DecodePathReader("$.{k}[{i}]", func(key []byte, value io.Reader) error {
+		b, err := io.ReadAll(value)
+		got = append(got, fmt.Sprint(dec.Captures().Map())+" "+string(b))
+		return err
+	}))
+	require.Equal(t, []string{`map[i:0 k:a] "x"`, `map[i:1 k:a] "y"`}, got)
+
+	got = got[0:0]
+	dec = NewStreamDecoder(bytes.NewReader(decodeHex(t, testMsgpack)), MessagePack(false))
+	require.NoError(t, dec.DecodeMatch("$.store.book[{b}].{field}", func(m *Match, message json.RawMessage) error {
+		got = append(got, fmt.Sprint(m.Captures.Map())+" "+string(message))
+		return nil
+	}, String))
+	require.Equal(t, []string{`map[b:0 field:title] "a"`, `map[b:1 field:title] "b"`}, got)
+}
+
+func TestCapturesEmbedded(t *testing.T) {
+	var got []string
+	input := `{"records": [{"msg": "{\"items\": [1, 2]}"}, {"msg": "{\"items\": [3]}"}]}`
+	dec := NewStreamDecoder(strings.NewReader(input))
+	require.NoError(t, dec.DecodePath("$.records[{r}].msg|json.items[{i}]", func(key []byte, message json.RawMessage) error {
+		got = append(got, string(key)+" "+fmt.Sprint(dec.Captures().Map()))
+		return nil
+	}))
+	require.Equal(t, []string{
+		"$.records[0].msg|json.items[0] map[i:0 r:0]",
+		"$.records[0].msg|json.items[1] map[i:1 r:0]",
+		"$.records[1].msg|json.items[0] map[i:0 r:1]",
+	}, got)
+
+	got = got[0:0]
+	require.NoError(t, NewStreamDecoder(strings.NewReader(input)).DecodeMatch("$.records[*].msg|json.{k}[{i}]", func(m *Match, message json.RawMessage) error {
+		got = append(got, fmt.Sprint(m.Captures.Map()))
+		return nil
+	}))
+	require.Equal(t, []string{"map[i:0 k:items]", "map[i:1 k:items]", "map[i:0 k:items]"}, got)
+}
+
+func TestCapturesInvalid(t *testing.T) {
+	for _, path := range []string{
+		"$.a[{i}].b[{i}]",
+		"$.a[x].{k}",
+		"$.a[{i}",
+		"a.{k}",
+	} {
+		t.Run(path, func(t *testing.T) {
+			err := NewStreamDecoder(strings.NewReader(`{}`)).DecodePath(path, func(key []byte, message json.RawMessage) error {
+				return nil
+			})
+			require.Error(t, err)
+		})
+	}
+
+	// glob alternatives are not captures
+	var got []string
+	require.NoError(t, NewStreamDecoder(strings.NewReader(`{"a": {"x": 1}, "b": {"x": 2}, "c": {"x": 3}}`)).DecodePath("$.{a,b}.*", func(key []byte, message json.RawMessage) error {
+		got = append(got, string(key))
+		return nil
+	}))
+	require.Equal(t, []string{"$.a.x", "$.b.x"}, got)
+}
@@ -0,0 +1,193 @@
+package jspath
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// CBOR makes the StreamDecoder read a CBOR (RFC 8949) stream, a sequence of
+// CBOR data items. Paths and matching work as for JSON, map keys that are
+// not strings are rendered as JSON in paths. Tags are transparent: a tagged
+// item has the path of the item it annotates.
+//
+// Matched items are handed over transcoded to JSON, or as their raw CBOR
+// encoding, tags included, when raw is set. Byte strings are transcoded to
+// base64 strings, bignums to JSON numbers, undefined to null and the other
+// tags to the item they annotate. Indefinite length items are transcoded to
+// their definite equivalent.
+func CBOR(raw bool) Option {
+	return func(dec *StreamDecoder) {
+		dec.format = cborFormat{}
+		dec.rawBinary = raw
+	}
+}
+
+const (
+	cborUnsigned = iota
+	cborNegative
+	cborBytes
+	cborString
+	cborArray
+	cborMap
+	cborTag
+	cborSimple
+)
+
+const (
+	cborTagBignum         = 2
+	cborTagNegativeBignum = 3
+)
+
+var (
+	errCBORReserved = errors.New("reserved CBOR additional information")
+	errCBORSimple   = errors.New("unsupported CBOR simple value")
+)
+
+type cborFormat struct{}
+
+// cborArgument parses the argument of the header data starts with.
+func cborArgument(data []byte) (arg uint64, head int, err error) {
+	info := data[0] & 0x1f
+	switch {
+	case info < 24:
+		return uint64(info), 1, nil
+	case info > 27:
+		return 0, 1, errCBORReserved
+	}
+	n := 1 << (info - 24)
+	if len(data) < 1+n {
+		return 0, 0, errBinaryShort
+	}
+	for _, b := range data[1 : 1+n] {
+		arg = arg<<8 | uint64(b)
+	}
+	return arg, 1 + n, nil
+}
+
+func (cborFormat) item(data []byte) (binaryItem, error) {
+	if len(data) == 0 {
+		return binaryItem{}, errBinaryShort
+	}
+	major, info := data[0]>>5, data[0]&0x1f
+	if info == 31 {
+		switch major {
+		case cborBytes:
+			return binaryItem{kind: binaryBytes, head: 1, length: -1}, nil
+		case cborString:
+			return binaryItem{kind: binaryString, head: 1, length: -1}, nil
+		case cborArray:
+			return binaryItem{kind: binaryArray, head: 1, length: -1}, nil
+		case cborMap:
+			return binaryItem{kind: binaryMap, head: 1, length: -1}, nil
+		case cborSimple:
+			return binaryItem{kind: binaryBreak, head: 1}, nil
+		}
+		return binaryItem{}, errCBORReserved
+	}
+	if major == cborSimple {
+		switch {
+		case info < 24:
+			return binaryItem{kind: binaryScalar, head: 1}, nil
+		case info < 28:
+			return binaryItem{kind: binaryScalar, head: 1 + 1<<(info-24)}, nil
+		}
+		return binaryItem{}, errCBORReserved
+	}
+	arg, head, err := cborArgument(data)
+	if err != nil {
+		return binaryItem{}, err
+	}
+	switch major {
+	case cborUnsigned, cborNegative:
+		return binaryItem{kind: binaryScalar, head: head}, nil
+	case cborTag:
+		return binaryItem{kind: binaryTag, head: head}, nil
+	}
+	if arg > math.MaxInt32 {
+		return binaryItem{}, errors.New("CBOR length overflow")
+	}
+	kind := binaryBytes
+	switch major {
+	case cborString:
+		kind = binaryString
+	case cborArray:
+		kind = binaryArray
+	case cborMap:
+		kind = binaryMap
+	}
+	return binaryItem{kind: kind, head: head, length: int(arg)}, nil
+}
+
+func (cborFormat) appendScalar(dst []byte, data []byte) ([]byte, error) {
+	major, info := data[0]>>5, data[0]&0x1f
+	if major == cborSimple {
+		switch info {
+		case 20:
+			return append(dst, "false"...), nil
+		case 21:
+			return append(dst, "true"...), nil
+		case 22, 23:
+			return append(dst, "null"...), nil
+		case 25:
+			return appendJSONFloat(dst, float64(float16(binary.BigEndian.Uint16(data[1:]))), 32)
+		case 26:
+			return appendJSONFloat(dst, float64(math.Float32frombits(binary.BigEndian.Uint32(data[1:]))), 32)
+		case 27:
+			return appendJSONFloat(dst, math.Float64frombits(binary.BigEndian.Uint64(data[1:])), 64)
+		}
+		return nil, errCBORSimple
+	}
+	arg, _, err := cborArgument(data)
+	if err != nil {
+		return nil, err
+	}
+	if major == cborUnsigned {
+		return strconv.AppendUint(dst, arg, 10), nil
+	}
+	// -1 - arg
+	if arg == math.MaxUint64 {
+		return append(dst, "-18446744073709551616"...), nil
+	}
+	dst = append(dst, '-')
+	return strconv.AppendUint(dst, arg+1, 10), nil
+}
+
+func (cborFormat) appendTagged(dst []byte, tag []byte, content []byte) ([]byte, bool, error) {
+	number, _, err := cborArgument(tag)
+	if err != nil {
+		return nil, false, err
+	}
+	switch number {
+	case cborTagBignum, cborTagNegativeBignum:
+		var n big.Int
+		n.SetBytes(content)
+		if number == cborTagNegativeBignum {
+			n.Add(&n, big.NewInt(1))
+			n.Neg(&n)
+		}
+		return n.Append(dst, 10), true, nil
+	}
+	return dst, false, nil
+}
+
+// float16 converts the IEEE 754 half precision number h.
+func float16(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1f
+	frac := uint32(h) & 0x3ff
+	switch exp {
+	case 0:
+		// subnormal
+		f := float32(frac) / (1 << 24)
+		if sign != 0 {
+			f = -f
+		}
+		return f
+	case 0x1f:
+		return math.Float32frombits(sign | 0xff<<23 | frac<<13)
+	}
+	return math.Float32frombits(sign | (exp+127-15)<<23 | frac<<13)
+}
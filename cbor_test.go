@@ -0,0 +1,181 @@
+package jspath
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+// {_ "device": "t1", "readings": [_ {"t": 1(1700000000), "v": 21.5}, {"t": 1700000060, "v": -3}], 1: true, "raw": h'010203'}
+// 55799([2(h'010000000000000000'), 3(h'010000000000000000'), -18446744073709551616, undefined, (_ "ab", "c"), null, 1.5])
+var testCBOR = "bf666465766963656274316872656164696e67739fa26174c11a6553f1006176f94d60a261741a6553f13c617622ff01f56372617743010203ff" +
+	"d9d9f787c249010000000000000000c3490100000000000000003bfffffffffffffffff77f6261626163fff6fa3fc00000"
+
+func TestDecodePathCBOR(t *testing.T) {
+	var testcases = []struct {
+		name string
+		path string
+		want []string
+	}{
+		{
+			name: "indefinite length array",
+			path: "$.readings[*]",
+			want: []string{`$.readings[0] {"t":1700000000,"v":21.5}`, `$.readings[1] {"t":1700000060,"v":-3}`},
+		},
+		{
+			name: "tagged member",
+			path: "$.readings[*].t",
+			want: []string{`$.readings[0].t 1700000000`, `$.readings[1].t 1700000060`},
+		},
+		{
+			name: "integer key",
+			path: "$.1",
+			want: []string{`$.1 true`},
+		},
+		{
+			name: "byte string",
+			path: "$.raw",
+			want: []string{`$.raw "AQID"`},
+		},
+		{
+			name: "tagged document",
+			path: "$.[*]",
+			want: []string{
+				`$.[0] 18446744073709551616`,
+				`$.[1] -18446744073709551617`,
+				`$.[2] -18446744073709551616`,
+				`$.[3] null`,
+				`$.[4] "abc"`,
+				`$.[5] null`,
+				`$.[6] 1.5`,
+			},
+		},
+		{
+			name: "whole documents",
+			path: "$",
+			want: []string{
+				`$ {"device":"t1","readings":[{"t":1700000000,"v":21.5},{"t":1700000060,"v":-3}],"1":true,"raw":"AQID"}`,
+				`$ [18446744073709551616,-18446744073709551617,-18446744073709551616,null,"abc",null,1.5]`,
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			dec := NewStreamDecoder(iotest.OneByteReader(bytes.NewReader(decodeHex(t, testCBOR))), CBOR(false))
+			require.NoError(t, dec.DecodePath(tc.path, func(key []byte, message json.RawMessage) error {
+				require.True(t, json.Valid(message), string(message))
+				got = append(got, string(key)+" "+string(message))
+				return nil
+			}))
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestDecodePathCBORRaw(t *testing.T) {
+	var got []string
+	dec := NewStreamDecoder(bytes.NewReader(decodeHex(t, testCBOR)), CBOR(true))
+	require.NoError(t, dec.DecodePath("$.readings[0]*", func(key []byte, message json.RawMessage) error {
+		got = append(got, string(key)+" "+hex.EncodeToString(message))
+		return nil
+	}))
+	require.Equal(t, []string{`$.readings[0] a26174c11a6553f1006176f94d60`}, got)
+
+	got = got[0:0]
+	dec = NewStreamDecoder(bytes.NewReader(decodeHex(t, testCBOR)), CBOR(true))
+	require.NoError(t, dec.DecodePath("$.readings[*].t", func(key []byte, message json.RawMessage) error {
+		got = append(got, string(key)+" "+hex.EncodeToString(message))
+		return nil
+	}))
+	require.Equal(t, []string{`$.readings[0].t c11a6553f100`, `$.readings[1].t 1a6553f13c`}, got)
+}
+
+func TestDecodePathCBORErrors(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		err   error
+	}{
+		{input: "bf6164", err: io.ErrUnexpectedEOF},
+		{input: "9f01", err: io.ErrUnexpectedEOF},
+		{input: "8201ff"},
+		{input: "1c"},
+		{input: "5f01ff"},
+	} {
+		err := NewStreamDecoder(bytes.NewReader(decodeHex(t, tc.input)), CBOR(false)).DecodePath("$", func(key []byte, message json.RawMessage) error {
+			return nil
+		})
+		require.Error(t, err, tc.input)
+		if tc.err != nil {
+			require.Equal(t, tc.err, err, tc.input)
+		}
+	}
+
+	err := NewStreamDecoder(bytes.NewReader(decodeHex(t, "8201ff")), CBOR(false)).DecodePath("$.none", func(key []byte, message json.RawMessage) error {
+		return nil
+	})
+	var serr *SyntaxError
+	require.True(t, errors.As(err, &serr), "%v", err)
+	require.Equal(t, int64(2), serr.Offset)
+}
+
+func TestDecodePathCBORChecks(t *testing.T) {
+	var testcases = []struct {
+		name  string
+		input string
+		path  string
+		opt   Option
+		want  error
+	}{
+		{
+			name:  "duplicate key",
+			input: "bf616101616102ff",
+			path:  "$.none",
+			opt:   Strict(),
+			want:  &StrictError{Path: "$.a", Offset: 4, Message: `duplicate key "a"`},
+		},
+		{
+			name:  "duplicate key in matched value",
+			input: "bf616101616102ff",
+			path:  "$",
+			opt:   Strict(),
+			want:  &StrictError{Path: "$.a", Offset: 4, Message: `duplicate key "a"`},
+		},
+		{
+			name:  "closed indefinite arrays",
+			input: "9f9fff9fffff",
+			path:  "$.none",
+			opt:   WithLimits(Limits{MaxDepth: 2}),
+		},
+		{
+			name:  "closed indefinite arrays in matched value",
+			input: "9f9fff9fffff",
+			path:  "$",
+			opt:   WithLimits(Limits{MaxDepth: 2}),
+		},
+		{
+			name:  "depth",
+			input: "9f9f9fffffff",
+			path:  "$.none",
+			opt:   WithLimits(Limits{MaxDepth: 2}),
+			want:  &LimitError{Limit: "MaxDepth", Max: 2, Path: "$.[0][0]", Offset: 2},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			dec := NewStreamDecoder(bytes.NewReader(decodeHex(t, tc.input)), CBOR(false), tc.opt)
+			err := dec.DecodePath(tc.path, func(key []byte, message json.RawMessage) error {
+				return nil
+			})
+			require.Equal(t, tc.want, err)
+		})
+	}
+}
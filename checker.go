@@ -0,0 +1,134 @@
+package jspath
+
+import "strconv"
+
+// A valueChecker inspects the whole input as it is decoded, matched values included.
+type valueChecker interface {
+	// open is called when entering an object or array at path.
+	open(delim byte, path []byte, offset int64) error
+	// close is called with the opening delimiter when leaving it.
+	close(delim byte)
+	// member is called with the quoted key of a member of the innermost object,
+	// path is the path of the member.
+	member(path []byte, quoted []byte, offset int64) error
+	// str is called with the quoted strings that are not keys.
+	str(path []byte, quoted []byte, offset int64) error
+	// save records the state of the open containers in cp, restore sets it back.
+	save(cp *Checkpoint)
+	restore(cp *Checkpoint) error
+	// reset forgets the open containers, for a new input.
+	reset()
+}
+
+// checkOpen checks the object or array opened at path, at the input offset.
+func (dec *StreamDecoder) checkOpen(delim byte, path []byte, offset int64) error {
+	for _, c := range dec.checkers {
+		if err := c.open(delim, path, offset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (dec *StreamDecoder) checkClose(delim byte) {
+	for _, c := range dec.checkers {
+		c.close(delim)
+	}
+}
+
+// checkMember checks the member key quoted, read at the input offset, before
+// it is set in the path, so that the limits apply before the path grows.
+func (dec *StreamDecoder) checkMember(quoted []byte, offset int64) error {
+	if len(dec.checkers) == 0 {
+		return nil
+	}
+	path := dec.path.PathBytes()
+	path = path[:len(path)-dec.path.stackSegmentsSizes.Peek()]
+	dec.checkPath = appendUnescaped(append(append(dec.checkPath[0:0], path...), '.'), quoted[1:len(quoted)-1])
+	for _, c := range dec.checkers {
+		if err := c.member(dec.checkPath, quoted, offset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkValue walks the value just read at path.
+func (dec *StreamDecoder) checkValue(path []byte, value []byte) error {
+	if value[0] != '{' && value[0] != '[' && value[0] != '"' {
+		return nil
+	}
+	for _, c := range dec.checkers {
+		dec.checkPath = append(dec.checkPath[0:0], path...)
+		if _, err := walkValue(c, dec.checkPath, value, 0, dec.offset()-int64(len(value))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkValue walks the valid JSON value at data[i:], at path, and returns the index after it.
+// offset is the input offset of data.
+func walkValue(c valueChecker, path []byte, data []byte, i int, offset int64) (int, error) {
+	i = skipSpace(data, i)
+	switch data[i] {
+	case '"':
+		end := stringEnd(data, i)
+		return end, c.str(path, data[i:end], offset+int64(i))
+	case '{':
+		if err := c.open('{', path, offset+int64(i)); err != nil {
+			return 0, err
+		}
+		defer c.close('{')
+		for i = skipSpace(data, i+1); data[i] != '}'; {
+			end := stringEnd(data, i)
+			child := appendUnescaped(append(path, '.'), data[i+1:end-1])
+			if err := c.member(child, data[i:end], offset+int64(i)); err != nil {
+				return 0, err
+			}
+			var err error
+			if i, err = walkValue(c, child, data, skipSpace(data, end)+1, offset); err != nil {
+				return 0, err
+			}
+			if i = skipSpace(data, i); data[i] == ',' {
+				i = skipSpace(data, i+1)
+			}
+		}
+		return i + 1, nil
+	case '[':
+		if err := c.open('[', path, offset+int64(i)); err != nil {
+			return 0, err
+		}
+		defer c.close('[')
+		if len(path) == 1 {
+			path = append(path, '.')
+		}
+		for index, i := 0, skipSpace(data, i+1); ; index++ {
+			if data[i] == ']' {
+				return i + 1, nil
+			}
+			child := append(strconv.AppendInt(append(path, '['), int64(index), 10), ']')
+			var err error
+			if i, err = walkValue(c, child, data, i, offset); err != nil {
+				return 0, err
+			}
+			if i = skipSpace(data, i); data[i] == ',' {
+				i = skipSpace(data, i+1)
+			}
+		}
+	}
+	for i < len(data) && !isTokenEnd(data[i]) {
+		i++
+	}
+	return i, nil
+}
+
+// stringEnd returns the index after the JSON string starting at data[i].
+func stringEnd(data []byte, i int) int {
+	for i++; data[i] != '"'; i++ {
+		if data[i] == '\\' {
+			i++
+		}
+	}
+	return i + 1
+}
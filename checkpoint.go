@@ -0,0 +1,105 @@
+package jspath
+
+import (
+	"errors"
+)
+
+// A Checkpoint is a serializable snapshot of the StreamDecoder state taken right
+// after a matched value has been handled.
+//
+// To resume an interrupted decode, seek the input to Offset and hand the
+// checkpoint to Restore before calling Decode or DecodePath again. Offset is
+// an offset in the input as read, the Lenient and AutoDecompress options
+// rewrite it, so they cannot be combined with checkpoints.
+type Checkpoint struct {
+	// Offset is the input offset right after the last handled value.
+	Offset int64 `json:"offset"`
+	// TokenState and TokenStack hold the tokenizer state at Offset.
+	TokenState int   `json:"tokenState"`
+	TokenStack []int `json:"tokenStack"`
+	// Path is the current path, array indices included.
+	Path string `json:"path"`
+	// PathSegments holds the size of each segment of Path, outermost first.
+	PathSegments []int `json:"pathSegments"`
+	// PathIndices holds the array index of each segment of Path, -1 for the
+	// root and the members.
+	PathIndices []int `json:"pathIndices"`
+	// Members holds the number of members read in each open container, -1 for
+	// arrays. It is only set with the WithLimits option.
+	Members []int `json:"members,omitempty"`
+	// Keys holds the unescaped keys read in each open object, outermost first.
+	// It is only set with the Strict option.
+	Keys [][]string `json:"keys,omitempty"`
+}
+
+var (
+	errInvalidCheckpoint     = errors.New("jspath: invalid checkpoint")
+	errCheckpointUnsupported = errors.New("jspath: checkpoints are not supported with Lenient or AutoDecompress")
+)
+
+// checkpointable reports whether the offsets of dec are those of its input.
+func (dec *StreamDecoder) checkpointable() bool {
+	return dec.dialect == 0 && len(dec.codecs) == 0
+}
+
+func (dec *StreamDecoder) checkpoint() Checkpoint {
+	cp := Checkpoint{
+		Offset:       dec.offset(),
+		TokenState:   dec.tokenState,
+		TokenStack:   append([]int(nil), dec.tokenStack...),
+		Path:         string(dec.path.PathBytes()),
+		PathSegments: append([]int(nil), dec.path.stackSegmentsSizes...),
+		PathIndices:  append([]int(nil), dec.path.indices...),
+	}
+	for _, c := range dec.checkers {
+		c.save(&cp)
+	}
+	return cp
+}
+
+// Restore sets the decoder state from cp. The reader must already be positioned
+// at cp.Offset, decoding then resumes right after the last acknowledged value.
+// It must be called before Decode or DecodePath, on a decoder created with the
+// options of the one that took the checkpoint.
+func (dec *StreamDecoder) Restore(cp Checkpoint) error {
+	if !dec.checkpointable() {
+		return errCheckpointUnsupported
+	}
+	if cp.Offset < 0 || cp.TokenState < tokenTopValue || cp.TokenState > tokenObjectComma {
+		return errInvalidCheckpoint
+	}
+	if len(cp.PathSegments) != len(cp.TokenStack)+1 || len(cp.PathIndices) != len(cp.PathSegments) {
+		return errInvalidCheckpoint
+	}
+	for _, state := range cp.TokenStack {
+		if state < tokenTopValue || state > tokenObjectComma {
+			return errInvalidCheckpoint
+		}
+	}
+	if err := dec.path.restore(cp.Path, cp.PathSegments, cp.PathIndices); err != nil {
+		return err
+	}
+	for _, c := range dec.checkers {
+		if err := c.restore(&cp); err != nil {
+			return err
+		}
+	}
+	dec.tokenState = cp.TokenState
+	dec.tokenStack = append(dec.tokenStack[0:0], cp.TokenStack...)
+	dec.scan.reset()
+	dec.buf = dec.buf[0:0]
+	dec.scanp = 0
+	dec.scanned = cp.Offset
+	return nil
+}
+
+// openObjects returns the number of objects among the containers open at cp.
+func (cp *Checkpoint) openObjects() int {
+	n := 0
+	for _, index := range cp.PathIndices[1:] {
+		if index < 0 {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,210 @@
+package jspath
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpointResume(t *testing.T) {
+	var testcases = []struct {
+		name  string
+		path  string
+		input string
+		stop  int
+	}{
+		{
+			name:  "array wildcard",
+			path:  "$.store.book[*]",
+			input: testdata,
+			stop:  2,
+		},
+		{
+			name:  "scalar values",
+			path:  "$.store.book[*].price",
+			input: testdata,
+			stop:  1,
+		},
+		{
+			name:  "multiple documents",
+			path:  "$.store.bicycle",
+			input: testdataMultiple,
+			stop:  1,
+		},
+		{
+			name:  "bracket keys",
+			path:  "$.*[*]",
+			input: `{"[1]": [1, {"[0]": [2]}], "x": [3]}`,
+			stop:  1,
+		},
+		{
+			name:  "root level values",
+			path:  "$.",
+			input: `"a" 3 ["s"] {"j":"j"} false`,
+			stop:  3,
+		},
+	}
+
+	errStop := errors.New("stop")
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var want []string
+			err := NewStreamDecoder(strings.NewReader(tc.input)).DecodePath(tc.path, func(key []byte, message json.RawMessage) error {
+				want = append(want, string(key)+"="+string(message))
+				return nil
+			})
+			require.NoError(t, err)
+
+			var got []string
+			var last []byte
+			dec := NewStreamDecoder(strings.NewReader(tc.input))
+			dec.WithCheckpoint(func(cp Checkpoint) error {
+				var err error
+				last, err = json.Marshal(cp)
+				require.NoError(t, err)
+				if len(got) == tc.stop {
+					return errStop
+				}
+				return nil
+			})
+			err = dec.DecodePath(tc.path, func(key []byte, message json.RawMessage) error {
+				got = append(got, string(key)+"="+string(message))
+				return nil
+			})
+			require.Equal(t, errStop, err)
+
+			var cp Checkpoint
+			require.NoError(t, json.Unmarshal(last, &cp))
+			dec = NewStreamDecoder(strings.NewReader(tc.input[cp.Offset:]))
+			require.NoError(t, dec.Restore(cp))
+			err = dec.DecodePath(tc.path, func(key []byte, message json.RawMessage) error {
+				got = append(got, string(key)+"="+string(message))
+				return nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, want, got)
+		})
+	}
+}
+
+func TestCheckpointRestoreInvalid(t *testing.T) {
+	var testcases = []struct {
+		name string
+		cp   Checkpoint
+	}{
+		{
+			name: "empty",
+			cp:   Checkpoint{},
+		},
+		{
+			name: "segments mismatch",
+			cp:   Checkpoint{Path: "$.a", PathSegments: []int{1, 1}, TokenStack: []int{tokenTopValue}},
+		},
+		{
+			name: "unknown token state",
+			cp:   Checkpoint{Path: "$", PathSegments: []int{1}, PathIndices: []int{-1}, TokenState: 42},
+		},
+		{
+			name: "missing indices",
+			cp:   Checkpoint{Path: "$.a[0]", PathSegments: []int{1, 2, 3}, TokenStack: []int{tokenTopValue, tokenObjectComma}},
+		},
+		{
+			name: "indices mismatch",
+			cp:   Checkpoint{Path: "$.a[0]", PathSegments: []int{1, 2, 3}, PathIndices: []int{-1, -1, 1}, TokenStack: []int{tokenTopValue, tokenObjectComma}},
+		},
+		{
+			name: "not a path",
+			cp:   Checkpoint{Path: "a", PathSegments: []int{1}, PathIndices: []int{-1}},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			dec := NewStreamDecoder(strings.NewReader(""))
+			require.Equal(t, errInvalidCheckpoint, dec.Restore(tc.cp))
+		})
+	}
+}
+
+func TestCheckpointCheckers(t *testing.T) {
+	var testcases = []struct {
+		name  string
+		path  string
+		input string
+		opt   Option
+		want  string
+	}{
+		{
+			name:  "duplicate key",
+			path:  "$.b[*]",
+			input: `{"a": 1, "b": [1, 2], "a": 3}`,
+			opt:   Strict(),
+			want:  `jspath: $.a at offset 22: duplicate key "a"`,
+		},
+		{
+			name:  "depth",
+			path:  "$.a[0]",
+			input: `{"a": [1, [[2]]]}`,
+			opt:   WithLimits(Limits{MaxDepth: 3}),
+			want:  "jspath: $.a[1][0] at offset 11: MaxDepth of 3 exceeded",
+		},
+		{
+			name:  "members",
+			path:  "$.a",
+			input: `{"a": 1, "b": 2, "c": 3}`,
+			opt:   WithLimits(Limits{MaxMembers: 2}),
+			want:  "jspath: $.c at offset 17: MaxMembers of 2 exceeded",
+		},
+	}
+
+	errStop := errors.New("stop")
+	handler := func(key []byte, message json.RawMessage) error {
+		return nil
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var last []byte
+			dec := NewStreamDecoder(strings.NewReader(tc.input), tc.opt)
+			dec.WithCheckpoint(func(cp Checkpoint) error {
+				var err error
+				last, err = json.Marshal(cp)
+				require.NoError(t, err)
+				return errStop
+			})
+			require.Equal(t, errStop, dec.DecodePath(tc.path, handler))
+
+			var cp Checkpoint
+			require.NoError(t, json.Unmarshal(last, &cp))
+			dec = NewStreamDecoder(strings.NewReader(tc.input[cp.Offset:]), tc.opt)
+			require.NoError(t, dec.Restore(cp))
+			err := dec.DecodePath(tc.path, handler)
+			require.Error(t, err)
+			require.Equal(t, tc.want, err.Error())
+
+			// the checker state must be in the checkpoint
+			dec = NewStreamDecoder(strings.NewReader(tc.input[cp.Offset:]), tc.opt)
+			require.Equal(t, errInvalidCheckpoint, dec.Restore(Checkpoint{
+				Offset: cp.Offset, TokenState: cp.TokenState, TokenStack: cp.TokenStack,
+				Path: cp.Path, PathSegments: cp.PathSegments, PathIndices: cp.PathIndices,
+			}))
+		})
+	}
+}
+
+func TestCheckpointUnsupported(t *testing.T) {
+	for _, opt := range []Option{Lenient(JSONC), AutoDecompress()} {
+		dec := NewStreamDecoder(strings.NewReader(`{"a": 1}`), opt)
+		dec.WithCheckpoint(func(cp Checkpoint) error {
+			return nil
+		})
+		require.Equal(t, errCheckpointUnsupported, dec.DecodePath("$.a", func(key []byte, message json.RawMessage) error {
+			return nil
+		}))
+
+		dec = NewStreamDecoder(strings.NewReader(`{"a": 1}`), opt)
+		require.Equal(t, errCheckpointUnsupported, dec.Restore(Checkpoint{Path: "$", PathSegments: []int{1}, PathIndices: []int{-1}}))
+	}
+}
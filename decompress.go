@@ -0,0 +1,200 @@
+package jspath
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// A Codec describes a compression format the StreamDecoder can detect and
+// decompress transparently.
+type Codec struct {
+	// Name identifies the codec in errors.
+	Name string
+	// Magic is the prefix that identifies the format.
+	Magic []byte
+	// Match optionally replaces the Magic prefix check, header holds the
+	// first bytes of the input.
+	Match func(header []byte) bool
+	// NewReader returns a reader decompressing r.
+	NewReader func(r io.Reader) (io.Reader, error)
+}
+
+func (c *Codec) match(header []byte) bool {
+	if c.Match != nil {
+		return c.Match(header)
+	}
+	return len(c.Magic) > 0 && bytes.HasPrefix(header, c.Magic)
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = []Codec{
+		{
+			Name:  "gzip",
+			Magic: []byte{0x1f, 0x8b},
+			NewReader: func(r io.Reader) (io.Reader, error) {
+				return gzip.NewReader(r)
+			},
+		},
+		{
+			Name:  "bzip2",
+			Magic: []byte("BZh"),
+			NewReader: func(r io.Reader) (io.Reader, error) {
+				return bzip2.NewReader(r), nil
+			},
+		},
+		{
+			Name: "zlib",
+			Match: func(header []byte) bool {
+				// CMF must announce deflate with a window of at most 32K and
+				// CMF/FLG must be a multiple of 31 (RFC 1950)
+				return len(header) >= 2 && header[0]&0x0f == 8 && header[0]>>4 <= 7 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0
+			},
+			NewReader: func(r io.Reader) (io.Reader, error) {
+				return zlib.NewReader(r)
+			},
+		},
+	}
+)
+
+// RegisterCodec makes a compression format available to AutoDecompress.
+// Codecs are tried in registration order, after the builtin gzip, bzip2 and zlib.
+func RegisterCodec(c Codec) {
+	codecsMu.Lock()
+	codecs = append(codecs, c)
+	codecsMu.Unlock()
+}
+
+// AutoDecompress sniffs the first bytes of the input and transparently
+// decompresses it when it matches a registered codec or one of extra.
+// Input that matches no codec, or whose header the codec rejects, is decoded as is.
+func AutoDecompress(extra ...Codec) Option {
+	return func(dec *StreamDecoder) {
+		codecsMu.RLock()
+		dec.codecs = append(append([]Codec(nil), codecs...), extra...)
+		codecsMu.RUnlock()
+	}
+}
+
+// A DecompressError is returned when the input cannot be decompressed.
+type DecompressError struct {
+	Codec string
+	// Offset is the amount of decompressed data produced before the error.
+	Offset int64
+	// CompressedOffset is the amount of compressed input read before the error,
+	// read-ahead included: the error is located before it, not at it.
+	CompressedOffset int64
+	Err              error
+}
+
+func (e *DecompressError) Error() string {
+	return fmt.Sprintf("jspath: %s: %v (offset %d, compressed offset %d)", e.Codec, e.Err, e.Offset, e.CompressedOffset)
+}
+
+func (e *DecompressError) Unwrap() error {
+	return e.Err
+}
+
+// sniff replaces dec.r by a decompressing reader when the input
+// starts with the magic bytes of one of dec.codecs.
+func (dec *StreamDecoder) sniff() error {
+	in := &countingReader{r: dec.r}
+	br := bufio.NewReader(in)
+	header := dec.sniffHeader(br)
+	for i := range dec.codecs {
+		codec := &dec.codecs[i]
+		if !codec.match(header) {
+			continue
+		}
+		rec := &recordingReader{r: br}
+		r, err := codec.NewReader(rec)
+		if err != nil {
+			// the header only looked like the format, like the JSON 80 1 does zlib
+			dec.r = io.MultiReader(bytes.NewReader(rec.recorded), br)
+			return nil
+		}
+		rec.recorded = nil
+		rec.done = true
+		dec.compressed = &decompressReader{r: r, in: in, codec: codec.Name}
+		dec.r = dec.compressed
+		return nil
+	}
+	dec.r = br
+	return nil
+}
+
+// sniffHeader peeks the first bytes of br without waiting for input the codecs do
+// not need: two bytes, what the reads returned, and more while they start a magic.
+// No JSON value is complete before two bytes, so the wait never delays one.
+func (dec *StreamDecoder) sniffHeader(br *bufio.Reader) []byte {
+	// a short input is not an error here, it is reported by the next read
+	header, err := br.Peek(2)
+	if err == nil {
+		header, err = br.Peek(br.Buffered())
+	}
+	for err == nil && dec.magicPrefix(header) {
+		header, err = br.Peek(len(header) + 1)
+	}
+	return header
+}
+
+// magicPrefix reports whether header is the start of the magic of one of dec.codecs.
+func (dec *StreamDecoder) magicPrefix(header []byte) bool {
+	for i := range dec.codecs {
+		magic := dec.codecs[i].Magic
+		if len(header) < len(magic) && bytes.HasPrefix(magic, header) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordingReader records what is read from r until it is done.
+type recordingReader struct {
+	r        io.Reader
+	recorded []byte
+	done     bool
+}
+
+func (r *recordingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if !r.done {
+		r.recorded = append(r.recorded, p[:n]...)
+	}
+	return n, err
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// decompressReader tracks the decompressed and compressed offsets
+// and reports them on failure.
+type decompressReader struct {
+	r     io.Reader
+	in    *countingReader
+	codec string
+	n     int64
+}
+
+func (d *decompressReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	d.n += int64(n)
+	if err != nil && err != io.EOF {
+		err = &DecompressError{Codec: d.codec, Offset: d.n, CompressedOffset: d.in.n, Err: err}
+	}
+	return n, err
+}
@@ -0,0 +1,180 @@
+package jspath
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// bzip2 of {"a":[1,2,3],"b":"x"}, the standard library has no bzip2 writer
+var bzip2Sample = []byte{
+	0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0xed, 0xf0,
+	0x68, 0x3f, 0x00, 0x00, 0x09, 0x9b, 0x80, 0x10, 0x04, 0x38, 0x10, 0x00,
+	0x0a, 0x30, 0x00, 0x00, 0x4a, 0x20, 0x00, 0x22, 0x26, 0x8c, 0x8d, 0xa9,
+	0xfa, 0x82, 0x01, 0xa0, 0x04, 0x88, 0xa0, 0xa4, 0x75, 0xbe, 0xde, 0x37,
+	0xab, 0x7c, 0x17, 0x72, 0x45, 0x38, 0x50, 0x90, 0xed, 0xf0, 0x68, 0x3f,
+}
+
+func gzipBytes(t *testing.T, s string) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write([]byte(s))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func zlibBytes(t *testing.T, s string) []byte {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	_, err := w.Write([]byte(s))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+// reverseCodec is a toy codec used to test codec registration
+var reverseCodec = Codec{
+	Name:  "reverse",
+	Magic: []byte("REV:"),
+	NewReader: func(r io.Reader) (io.Reader, error) {
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		b = b[4:]
+		for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+			b[i], b[j] = b[j], b[i]
+		}
+		return bytes.NewReader(b), nil
+	},
+}
+
+func TestAutoDecompress(t *testing.T) {
+	const doc = `{"a":[1,2,3],"b":"x"}`
+	var testcases = []struct {
+		name  string
+		input []byte
+	}{
+		{
+			name:  "plain",
+			input: []byte(doc),
+		},
+		{
+			name:  "gzip",
+			input: gzipBytes(t, doc),
+		},
+		{
+			name:  "zlib",
+			input: zlibBytes(t, doc),
+		},
+		{
+			name:  "bzip2",
+			input: bzip2Sample,
+		},
+		{
+			name:  "extra codec",
+			input: []byte("REV:" + `}"x":"b",]3,2,1[:"a"{`),
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			dec := NewStreamDecoder(bytes.NewReader(tc.input), AutoDecompress(reverseCodec))
+			var results []string
+			err := dec.DecodePath("$.a[*]", func(key []byte, message json.RawMessage) error {
+				results = append(results, string(message))
+				return nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, []string{"1", "2", "3"}, results)
+		})
+	}
+}
+
+func TestAutoDecompressShortReads(t *testing.T) {
+	t.Run("pipe", func(t *testing.T) {
+		pr, pw := io.Pipe()
+		defer pw.Close()
+		go pw.Write([]byte(`{"a":1}`))
+		got := make(chan string, 1)
+		go NewStreamDecoder(pr, AutoDecompress()).DecodePath("$.a", func(key []byte, message json.RawMessage) error {
+			got <- string(message)
+			return nil
+		})
+		select {
+		case message := <-got:
+			require.Equal(t, "1", message)
+		case <-time.After(5 * time.Second):
+			t.Fatal("the value was not delivered before the end of the input")
+		}
+	})
+
+	t.Run("one byte reads", func(t *testing.T) {
+		for _, input := range [][]byte{gzipBytes(t, `{"a":1}`), zlibBytes(t, `{"a":1}`), bzip2Sample, []byte(`REV:}1:"a"{`)} {
+			var results []string
+			dec := NewStreamDecoder(iotest.OneByteReader(bytes.NewReader(input)), AutoDecompress(reverseCodec))
+			require.NoError(t, dec.DecodePath("$.a", func(key []byte, message json.RawMessage) error {
+				results = append(results, string(message))
+				return nil
+			}))
+			require.Len(t, results, 1)
+		}
+	})
+}
+
+func TestAutoDecompressErrors(t *testing.T) {
+	t.Run("corrupted stream", func(t *testing.T) {
+		input := gzipBytes(t, strings.Repeat(testdata, 10))
+		input = input[:len(input)/2]
+		dec := NewStreamDecoder(bytes.NewReader(input), AutoDecompress())
+		err := dec.DecodePath("$.store.book[*]", func(key []byte, message json.RawMessage) error {
+			return nil
+		})
+		var derr *DecompressError
+		require.True(t, errors.As(err, &derr), "%v", err)
+		require.Equal(t, "gzip", derr.Codec)
+		require.Equal(t, int64(len(input)), derr.CompressedOffset)
+		require.True(t, derr.Offset > 0)
+	})
+
+	t.Run("syntax error", func(t *testing.T) {
+		input := gzipBytes(t, `{"a":[1,2,}`)
+		dec := NewStreamDecoder(bytes.NewReader(input), AutoDecompress())
+		err := dec.DecodePath("$.a", func(key []byte, message json.RawMessage) error {
+			return nil
+		})
+		var serr *SyntaxError
+		require.True(t, errors.As(err, &serr), "%v", err)
+		require.Equal(t, int64(len(input)), serr.CompressedOffset)
+	})
+}
+
+func TestAutoDecompressLookalikes(t *testing.T) {
+	for _, input := range []string{"80 1", "80 1 2 3 4 5 6 7 8", "x^ 1"} {
+		t.Run(input, func(t *testing.T) {
+			var results []string
+			dec := NewStreamDecoder(strings.NewReader(input), AutoDecompress())
+			err := dec.DecodePath("$.", func(key []byte, message json.RawMessage) error {
+				results = append(results, string(message))
+				return nil
+			})
+			if input[0] == 'x' {
+				// a zlib header, the input is not JSON either way
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, strings.Fields(input), results)
+		})
+	}
+}
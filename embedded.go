@@ -0,0 +1,162 @@
+package jspath
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// embeddedJSON in a path treats the string matched by the path before it as a
+// JSON document, matched by the path after it:
+//
+//	$.Records[*].Message|json.a
+//
+// matches the member a of the documents serialized in the Message strings. The
+// matched values are reported at $.Records[0].Message|json.a and so on, values
+// that are not strings are ignored. Paths may descend into several levels. The
+// offsets, depths and locations of the Match of these values are relative to
+// their document, their captures are those of the whole path.
+//
+// |json is only recognized at the end of a segment, \|json matches member
+// names ending with |json. The documents are decoded with the options and the
+// context of the decoder, their depth counts against the limits of the input.
+const embeddedJSON = "|json"
+
+// embeddedIndex returns the index of the first |json segment suffix of path, -1 if none.
+func embeddedIndex(path string) int {
+	for i := 0; i+len(embeddedJSON) <= len(path); i++ {
+		if path[i] == '\\' {
+			i++
+			continue
+		}
+		if !strings.HasPrefix(path[i:], embeddedJSON) {
+			continue
+		}
+		if end := i + len(embeddedJSON); end == len(path) || path[end] == '.' || path[end] == '[' {
+			return i
+		}
+	}
+	return -1
+}
+
+// unescapePath returns the literal path matched by the exact path, \| stands for |.
+func unescapePath(path string) string {
+	return strings.Replace(path, `\|`, "|", -1)
+}
+
+// embeddedUnmarshaler decodes the strings matched at path as JSON documents.
+type embeddedUnmarshaler struct {
+	path    string
+	target  embeddedTarget
+	decoder decoder
+	doc     []byte
+	outer   *StreamDecoder
+	inner   *StreamDecoder
+}
+
+// embeddedTarget hands the values matched inside a document to the
+// unmarshaler of the whole path, their key prefixed by the path of the document.
+type embeddedTarget struct {
+	path   string
+	prefix []byte
+	key    []byte
+	u      UnmarshalerStream
+	// err is the last error returned by u.
+	err error
+	// captures holds the n captures of the path of the document, followed by
+	// those of the value once matched.
+	captures Captures
+	n        int
+	outer    *StreamDecoder
+	inner    *StreamDecoder
+}
+
+func (dec *StreamDecoder) newEmbeddedUnmarshaler(u UnmarshalerStream) (*embeddedUnmarshaler, error) {
+	path := u.AtPath()
+	i := embeddedIndex(path)
+	e := &embeddedUnmarshaler{
+		path:  path[:i],
+		outer: dec,
+		inner: NewStreamDecoder(bytes.NewReader(nil)),
+	}
+	// the documents are decoded as a value of the input, the checkers are
+	// shared so that their depth adds to the depth of the string
+	e.inner.dialect = dec.dialect
+	e.inner.limits = dec.limits
+	e.inner.checkers = dec.checkers
+	rest := path[i+len(embeddedJSON):]
+	if strings.HasPrefix(rest, "[") {
+		// the elements of top level arrays are rendered as $.[0]
+		rest = "." + rest
+	}
+	e.target = embeddedTarget{path: "$" + rest, u: u, outer: dec, inner: e.inner}
+	var err error
+	if e.decoder, err = e.inner.newDecoder(&e.target); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *embeddedUnmarshaler) AtPath() string {
+	return e.path
+}
+
+func (e *embeddedUnmarshaler) UnmarshalStream(key []byte, message json.RawMessage) error {
+	if message[0] != '"' {
+		return nil
+	}
+	e.doc = appendUnescaped(e.doc[0:0], message[1:len(message)-1])
+	e.target.prefix = append(append(e.target.prefix[0:0], key...), embeddedJSON...)
+	e.target.captures = append(e.target.captures[0:0], e.outer.captures...)
+	e.target.n = len(e.target.captures)
+	e.inner.reset(bytes.NewReader(e.doc))
+	e.inner.context = e.outer.context
+	e.target.err = nil
+	go e.inner.decode(e.decoder)
+	<-e.inner.Done()
+	if err := e.inner.err; err != nil && err != e.target.err {
+		return fmt.Errorf("jspath: %s: %w", e.target.prefix, err)
+	}
+	return e.inner.err
+}
+
+func (t *embeddedTarget) AtPath() string {
+	return t.path
+}
+
+func (t *embeddedTarget) UnmarshalStream(key []byte, message json.RawMessage) error {
+	t.key = append(append(t.key[0:0], t.prefix...), key[1:]...)
+	t.capture()
+	t.err = t.u.UnmarshalStream(t.key, message)
+	return t.err
+}
+
+// capture appends the captures of the matched value to those of its document,
+// and sets them as the captures of the outer decoder for the handler.
+func (t *embeddedTarget) capture() {
+	t.captures = append(t.captures[:t.n], t.inner.captures...)
+	t.outer.captures = nil
+	if len(t.captures) > 0 {
+		t.outer.captures = t.captures
+	}
+}
+
+func (t *embeddedTarget) Kinds() []Kind {
+	if mu, ok := t.u.(MatchUnmarshalerStream); ok {
+		return mu.Kinds()
+	}
+	return nil
+}
+
+func (t *embeddedTarget) UnmarshalMatch(m *Match, message json.RawMessage) error {
+	mu, ok := t.u.(MatchUnmarshalerStream)
+	if !ok {
+		return t.UnmarshalStream(m.Path, message)
+	}
+	t.key = append(append(t.key[0:0], t.prefix...), m.Path[1:]...)
+	t.capture()
+	m.Path, m.Captures = t.key, t.outer.captures
+	t.err = mu.UnmarshalMatch(m, message)
+	return t.err
+}
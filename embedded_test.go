@@ -0,0 +1,144 @@
+package jspath
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodePathEmbedded(t *testing.T) {
+	input := `{"Records": [
+		{"Message": "{\"a\": 1, \"b\": {\"c\": [\"x\", \"y\"]}}"},
+		{"Message": "{\"a\": \"two\", \"nested\": \"{\\\"d\\\": true}\"}"},
+		{"Message": 3},
+		{"Message": "[{\"a\": 4}]"}
+	]}`
+	var testcases = []struct {
+		name string
+		path string
+		want []string
+	}{
+		{
+			name: "member",
+			path: "$.Records[*].Message|json.a",
+			want: []string{`$.Records[0].Message|json.a 1`, `$.Records[1].Message|json.a "two"`},
+		},
+		{
+			name: "whole document",
+			path: "$.Records[0].Message|json",
+			want: []string{`$.Records[0].Message|json {"a": 1, "b": {"c": ["x", "y"]}}`},
+		},
+		{
+			name: "glob inside",
+			path: "$.Records[0].Message|json.b.c[*]",
+			want: []string{`$.Records[0].Message|json.b.c[0] "x"`, `$.Records[0].Message|json.b.c[1] "y"`},
+		},
+		{
+			name: "root array",
+			path: "$.Records[*].Message|json.[*].a",
+			want: []string{`$.Records[3].Message|json.[0].a 4`},
+		},
+		{
+			name: "nested documents",
+			path: "$.Records[*].Message|json.nested|json.d",
+			want: []string{`$.Records[1].Message|json.nested|json.d true`},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			require.NoError(t, NewStreamDecoder(strings.NewReader(input)).DecodePath(tc.path, func(key []byte, message json.RawMessage) error {
+				got = append(got, string(key)+" "+string(message))
+				return nil
+			}))
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestDecodePathEmbeddedErrors(t *testing.T) {
+	err := NewStreamDecoder(strings.NewReader(`{"m": "{\"a\": }"}`)).DecodePath("$.m|json.a", func(key []byte, message json.RawMessage) error {
+		return nil
+	})
+	var serr *SyntaxError
+	require.True(t, errors.As(err, &serr), "%v", err)
+	require.Contains(t, err.Error(), "$.m|json")
+
+	errStop := errors.New("stop")
+	err = NewStreamDecoder(strings.NewReader(`{"m": "{\"a\": 1}"}`)).DecodePath("$.m|json.a", func(key []byte, message json.RawMessage) error {
+		return errStop
+	})
+	require.Equal(t, errStop, err)
+}
+
+func TestDecodePathEmbeddedOptions(t *testing.T) {
+	input := `{"m": "{\"a\": {\"b\": [1]}, \"a\": 2}"}`
+	var testcases = []struct {
+		name string
+		opts []Option
+		err  interface{}
+	}{
+		{
+			name: "depth counts against the input",
+			opts: []Option{WithLimits(Limits{MaxDepth: 3})},
+			err:  &LimitError{},
+		},
+		{
+			name: "strict",
+			opts: []Option{Strict()},
+			err:  &StrictError{},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := NewStreamDecoder(strings.NewReader(input), tc.opts...).DecodePath("$.m|json.x", func(key []byte, message json.RawMessage) error {
+				return nil
+			})
+			require.Error(t, err)
+			require.True(t, errors.As(err, &tc.err), "%v", err)
+		})
+	}
+
+	// the documents are read as the input is
+	var got []string
+	require.NoError(t, NewStreamDecoder(strings.NewReader(`{"m": "{a: [1, 2,], // c\n}"}`), Lenient(JSON5)).DecodePath("$.m|json.a", func(key []byte, message json.RawMessage) error {
+		got = append(got, string(message))
+		return nil
+	}))
+	require.Equal(t, []string{"[1, 2 ]"}, got)
+
+	// the documents are canceled with the input
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dec := NewStreamDecoder(strings.NewReader(`{"m": "[1, 2, 3]"}`))
+	dec.WithContext(ctx)
+	calls := 0
+	err := dec.DecodePath("$.m|json[*]", func(key []byte, message json.RawMessage) error {
+		calls++
+		cancel()
+		return nil
+	})
+	require.True(t, errors.Is(err, context.Canceled), "%v", err)
+	require.Equal(t, 1, calls)
+}
+
+func TestDecodePathEmbeddedSegment(t *testing.T) {
+	var got []string
+	input := `{"a|json": 1, "a|jsonb": 2, "c": "{\"d\": 3}"}`
+	for _, path := range []string{`$.a\|json`, "$.a|jsonb", "$.c|json.d", `$.*\|json`} {
+		require.NoError(t, NewStreamDecoder(strings.NewReader(input)).DecodePath(path, func(key []byte, message json.RawMessage) error {
+			got = append(got, path+" "+string(key)+"="+string(message))
+			return nil
+		}))
+	}
+	require.Equal(t, []string{
+		`$.a\|json $.a|json=1`,
+		`$.a|jsonb $.a|jsonb=2`,
+		`$.c|json.d $.c|json.d=3`,
+		`$.*\|json $.a|json=1`,
+	}, got)
+}
@@ -0,0 +1,288 @@
+package jspath
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+)
+
+// A StreamEncoder writes a JSON document incrementally, addressing its members by json path.
+//
+// Members are written in the order they are set, so paths must be given in document order:
+// setting a path closes the containers that are not among its parents and opens
+// the missing parent objects. A path cannot reopen a member already written, as
+// in Set("$.a", 1) then Set("$.a.b", 2). Paths only address object members
+// ("$.a.b"), array elements are written with Append.
+type StreamEncoder struct {
+	w   *bufio.Writer
+	err error
+
+	prefix string
+	indent string
+
+	path      pathBuilder
+	frames    []encodeFrame
+	documents int
+}
+
+type encodeFrame struct {
+	delim   byte
+	members int
+	// keys holds the names of the members written in an object.
+	keys map[string]bool
+}
+
+var (
+	errEncoderPath     = errors.New("jspath: encoder paths only address object members")
+	errEncoderNotArray = errors.New("jspath: no array to append to")
+	errEncoderNoFrame  = errors.New("jspath: no container to end")
+	errEncoderParent   = errors.New("jspath: path parent is not an object")
+	errEncoderReopen   = errors.New("jspath: path reopens a member already written")
+)
+
+// NewStreamEncoder returns a new StreamEncoder that writes to w.
+func NewStreamEncoder(w io.Writer) *StreamEncoder {
+	return &StreamEncoder{w: bufio.NewWriter(w), path: newPathBuilder()}
+}
+
+// SetIndent instructs the encoder to format the output like json.MarshalIndent.
+func (enc *StreamEncoder) SetIndent(prefix, indent string) {
+	enc.prefix = prefix
+	enc.indent = indent
+}
+
+// Begin opens an array at jsPath, its elements are written with Append.
+func (enc *StreamEncoder) Begin(jsPath string) error {
+	if err := enc.member(jsPath); err != nil {
+		return err
+	}
+	enc.open('[')
+	return enc.err
+}
+
+// BeginObject opens an object at jsPath.
+func (enc *StreamEncoder) BeginObject(jsPath string) error {
+	if err := enc.member(jsPath); err != nil {
+		return err
+	}
+	enc.open('{')
+	return enc.err
+}
+
+// Append writes v as the next element of the innermost open array.
+func (enc *StreamEncoder) Append(v interface{}) error {
+	if enc.err != nil {
+		return enc.err
+	}
+	n := len(enc.frames)
+	if n == 0 || enc.frames[n-1].delim != '[' {
+		return errEncoderNotArray
+	}
+	if enc.frames[n-1].members > 0 {
+		enc.path.IncrementArrayIndex()
+	}
+	enc.separator()
+	return enc.value(v)
+}
+
+// Set writes v at jsPath.
+func (enc *StreamEncoder) Set(jsPath string, v interface{}) error {
+	if err := enc.member(jsPath); err != nil {
+		return err
+	}
+	return enc.value(v)
+}
+
+// End closes the innermost open container.
+func (enc *StreamEncoder) End() error {
+	if enc.err != nil {
+		return enc.err
+	}
+	if len(enc.frames) == 0 {
+		return errEncoderNoFrame
+	}
+	enc.close()
+	return enc.err
+}
+
+// Close closes all the open containers and flushes the output.
+func (enc *StreamEncoder) Close() error {
+	if enc.err != nil {
+		return enc.err
+	}
+	for len(enc.frames) > 0 {
+		enc.close()
+	}
+	if err := enc.w.Flush(); err != nil && enc.err == nil {
+		enc.err = err
+	}
+	return enc.err
+}
+
+// Path returns the path of the last written member.
+func (enc *StreamEncoder) Path() string {
+	return string(enc.path.PathBytes())
+}
+
+// member positions the encoder on the object member at jsPath, closing and
+// opening containers as needed, and writes its key.
+func (enc *StreamEncoder) member(jsPath string) error {
+	if enc.err != nil {
+		return enc.err
+	}
+	names, err := splitMemberPath(jsPath)
+	if err != nil {
+		return err
+	}
+	// keep the open containers that are parents of jsPath
+	keep := 0
+	target := "$"
+	path := enc.path.PathBytes()
+	end := 0
+	for j := range enc.frames {
+		if j >= len(names) {
+			// a root value starts a new document
+			break
+		}
+		if j > 0 {
+			target += "." + names[j-1]
+		}
+		end += enc.path.stackSegmentsSizes[j]
+		if string(path[:end]) != target {
+			break
+		}
+		keep = j + 1
+	}
+	if keep > 0 && enc.frames[keep-1].keys[names[keep-1]] {
+		return errEncoderReopen
+	}
+	for len(enc.frames) > keep {
+		enc.close()
+	}
+	for j := keep; j < len(names); j++ {
+		if j > 0 {
+			if err := enc.key(names[j-1]); err != nil {
+				return err
+			}
+		} else {
+			enc.separator()
+		}
+		enc.open('{')
+	}
+	if len(names) == 0 {
+		enc.separator()
+		return enc.err
+	}
+	return enc.key(names[len(names)-1])
+}
+
+// key writes the key of a new member of the innermost open object.
+func (enc *StreamEncoder) key(name string) error {
+	n := len(enc.frames)
+	if n == 0 || enc.frames[n-1].delim != '{' {
+		return errEncoderParent
+	}
+	if enc.frames[n-1].keys == nil {
+		enc.frames[n-1].keys = map[string]bool{}
+	}
+	enc.frames[n-1].keys[name] = true
+	enc.path.SetObjectKey([]byte(name))
+	enc.separator()
+	key, err := json.Marshal(name)
+	if err != nil {
+		enc.err = err
+		return err
+	}
+	enc.w.Write(key)
+	enc.w.WriteByte(':')
+	if enc.indent != "" || enc.prefix != "" {
+		enc.w.WriteByte(' ')
+	}
+	return nil
+}
+
+// separator writes what comes before a new member of the innermost open container.
+func (enc *StreamEncoder) separator() {
+	n := len(enc.frames)
+	if n == 0 {
+		if enc.documents > 0 {
+			enc.w.WriteByte('\n')
+		}
+		enc.documents++
+		return
+	}
+	if enc.frames[n-1].members > 0 {
+		enc.w.WriteByte(',')
+	}
+	enc.frames[n-1].members++
+	enc.newline(n)
+}
+
+func (enc *StreamEncoder) newline(depth int) {
+	if enc.indent == "" && enc.prefix == "" {
+		return
+	}
+	enc.w.WriteByte('\n')
+	enc.w.WriteString(enc.prefix)
+	enc.w.WriteString(strings.Repeat(enc.indent, depth))
+}
+
+func (enc *StreamEncoder) open(delim byte) {
+	enc.w.WriteByte(delim)
+	enc.frames = append(enc.frames, encodeFrame{delim: delim})
+	if delim == '[' {
+		enc.path.StartArray()
+	} else {
+		enc.path.StartObject()
+	}
+}
+
+func (enc *StreamEncoder) close() {
+	frame := enc.frames[len(enc.frames)-1]
+	enc.frames = enc.frames[:len(enc.frames)-1]
+	if frame.members > 0 {
+		enc.newline(len(enc.frames))
+	}
+	if frame.delim == '[' {
+		enc.path.EndArray()
+		enc.w.WriteByte(']')
+	} else {
+		enc.path.EndObject()
+		enc.w.WriteByte('}')
+	}
+}
+
+func (enc *StreamEncoder) value(v interface{}) error {
+	var b []byte
+	var err error
+	if enc.indent == "" && enc.prefix == "" {
+		b, err = json.Marshal(v)
+	} else {
+		b, err = json.MarshalIndent(v, enc.prefix+strings.Repeat(enc.indent, len(enc.frames)), enc.indent)
+	}
+	if err != nil {
+		enc.err = err
+		return err
+	}
+	_, enc.err = enc.w.Write(b)
+	return enc.err
+}
+
+// splitMemberPath returns the member names of a path like $.a.b
+func splitMemberPath(jsPath string) ([]string, error) {
+	if jsPath == "$" || jsPath == "$." {
+		return nil, nil
+	}
+	if !strings.HasPrefix(jsPath, "$.") || strings.ContainsAny(jsPath, "[]*") {
+		return nil, errEncoderPath
+	}
+	names := strings.Split(jsPath[2:], ".")
+	for _, name := range names {
+		if name == "" {
+			return nil, errEncoderPath
+		}
+	}
+	return names, nil
+}
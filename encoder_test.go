@@ -0,0 +1,186 @@
+package jspath
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamEncoder(t *testing.T) {
+	var testcases = []struct {
+		name   string
+		indent string
+		encode func(enc *StreamEncoder) error
+		want   string
+	}{
+		{
+			name: "array and members",
+			encode: func(enc *StreamEncoder) error {
+				require.NoError(t, enc.Begin("$.items"))
+				for i := 0; i < 3; i++ {
+					require.NoError(t, enc.Append(map[string]int{"id": i}))
+				}
+				require.Equal(t, "$.items[2]", enc.Path())
+				require.NoError(t, enc.Set("$.meta.count", 3))
+				require.NoError(t, enc.Set("$.meta.next", nil))
+				return enc.Set("$.done", true)
+			},
+			want: `{"items":[{"id":0},{"id":1},{"id":2}],"meta":{"count":3,"next":null},"done":true}`,
+		},
+		{
+			name: "nested containers",
+			encode: func(enc *StreamEncoder) error {
+				require.NoError(t, enc.BeginObject("$.a.b"))
+				require.NoError(t, enc.Begin("$.a.b.c"))
+				require.NoError(t, enc.Append(1))
+				require.NoError(t, enc.End())
+				require.NoError(t, enc.Begin("$.a.b.d"))
+				require.NoError(t, enc.End())
+				return enc.Set("$.a.e", "x")
+			},
+			want: `{"a":{"b":{"c":[1],"d":[]},"e":"x"}}`,
+		},
+		{
+			name: "root array",
+			encode: func(enc *StreamEncoder) error {
+				require.NoError(t, enc.Begin("$"))
+				require.NoError(t, enc.Append("a"))
+				return enc.Append(json.RawMessage(`{"b": 1}`))
+			},
+			want: `["a",{"b":1}]`,
+		},
+		{
+			name: "multiple documents",
+			encode: func(enc *StreamEncoder) error {
+				require.NoError(t, enc.Set("$.a", 1))
+				require.NoError(t, enc.End())
+				require.NoError(t, enc.Set("$.a", 2))
+				return enc.Set("$", 3)
+			},
+			want: "{\"a\":1}\n{\"a\":2}\n3",
+		},
+		{
+			name:   "indent",
+			indent: "  ",
+			encode: func(enc *StreamEncoder) error {
+				require.NoError(t, enc.Begin("$.items"))
+				require.NoError(t, enc.Append(map[string]int{"id": 1}))
+				require.NoError(t, enc.Append(2))
+				return enc.Set("$.meta.count", 2)
+			},
+			want: `{
+  "items": [
+    {
+      "id": 1
+    },
+    2
+  ],
+  "meta": {
+    "count": 2
+  }
+}`,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			enc := NewStreamEncoder(&out)
+			enc.SetIndent("", tc.indent)
+			require.NoError(t, tc.encode(enc))
+			require.NoError(t, enc.Close())
+			require.Equal(t, tc.want, out.String())
+		})
+	}
+}
+
+func TestStreamEncoderPath(t *testing.T) {
+	enc := NewStreamEncoder(io.Discard)
+	require.NoError(t, enc.Set("$.meta.count", 1))
+	path := enc.Path()
+	require.NoError(t, enc.Set("$.meta.zz", 2))
+	require.Equal(t, "$.meta.count", path)
+	require.Equal(t, "$.meta.zz", enc.Path())
+}
+
+func TestStreamEncoderErrors(t *testing.T) {
+	var testcases = []struct {
+		name   string
+		encode func(enc *StreamEncoder) error
+		want   error
+	}{
+		{
+			name: "array index in path",
+			encode: func(enc *StreamEncoder) error {
+				return enc.Set("$.items[0]", 1)
+			},
+			want: errEncoderPath,
+		},
+		{
+			name: "append outside array",
+			encode: func(enc *StreamEncoder) error {
+				require.NoError(t, enc.Set("$.a", 1))
+				return enc.Append(2)
+			},
+			want: errEncoderNotArray,
+		},
+		{
+			name: "member of array",
+			encode: func(enc *StreamEncoder) error {
+				require.NoError(t, enc.Begin("$.a"))
+				return enc.Set("$.a.b", 1)
+			},
+			want: errEncoderParent,
+		},
+		{
+			name: "reopen scalar member",
+			encode: func(enc *StreamEncoder) error {
+				require.NoError(t, enc.Set("$.a", 1))
+				return enc.Set("$.a.b", 2)
+			},
+			want: errEncoderReopen,
+		},
+		{
+			name: "reopen closed object",
+			encode: func(enc *StreamEncoder) error {
+				require.NoError(t, enc.Set("$.a.b", 1))
+				require.NoError(t, enc.Set("$.c", 2))
+				return enc.Set("$.a.d", 3)
+			},
+			want: errEncoderReopen,
+		},
+		{
+			name: "set member twice",
+			encode: func(enc *StreamEncoder) error {
+				require.NoError(t, enc.Set("$.a.b", 1))
+				return enc.Set("$.a.b", 2)
+			},
+			want: errEncoderReopen,
+		},
+		{
+			name: "replace open object",
+			encode: func(enc *StreamEncoder) error {
+				require.NoError(t, enc.Set("$.a.b", 1))
+				return enc.Set("$.a", 2)
+			},
+			want: errEncoderReopen,
+		},
+		{
+			name: "end without container",
+			encode: func(enc *StreamEncoder) error {
+				return enc.End()
+			},
+			want: errEncoderNoFrame,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			require.Equal(t, tc.want, tc.encode(NewStreamEncoder(&out)))
+		})
+	}
+}
@@ -0,0 +1,345 @@
+package jspath
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"io"
+	"math/big"
+	"os"
+	"sync"
+)
+
+// ErrTooManyGroups is returned by GroupBy.Aggregate when MaxGroups is exceeded without an Other group.
+var ErrTooManyGroups = errors.New("jspath: too many groups")
+
+var errGroupPath = errors.New("jspath: group paths only address object members")
+
+// spillPartitions is the number of files the groups are spilled to.
+const spillPartitions = 16
+
+// A GroupBy computes aggregations over the values at a path grouped by a key,
+// like the sum of $.price grouped by $.category over $.store.book[*].
+//
+// Key and Value are paths relative to each matched value: "$.category" is the
+// category member of the matched value and "$" the matched value itself.
+type GroupBy struct {
+	// Key is the path of the group key, values without it are grouped under null.
+	Key string
+	// Value is the path of the aggregated value, "$" when empty.
+	Value string
+	// Aggregations are computed for every group.
+	Aggregations []Aggregation
+
+	// MaxGroups, when positive, bounds the number of groups. The values of
+	// the extra groups are aggregated under Other, or ErrTooManyGroups is returned
+	// when Other is nil. The keys of the groups are kept in memory to enforce it.
+	MaxGroups int
+	Other     json.RawMessage
+
+	// SpillLimit, when positive, is the number of groups held in memory:
+	// beyond it the groups are spilled to temporary files in SpillDir
+	// (os.TempDir when empty) and merged back once the input is exhausted.
+	SpillLimit int
+	SpillDir   string
+
+	// Options are handed to the underlying StreamDecoder.
+	Options []Option
+}
+
+type group struct {
+	key []byte
+	agg aggregator
+}
+
+type grouper struct {
+	*GroupBy
+	key, value []string
+
+	groups map[string]*group
+	order  []*group
+	known  map[string]bool
+	spills []*os.File
+}
+
+// Aggregate computes the aggregations of the groups over the values at jsPath
+// in the JSON read from r, then calls fn with every group key and its results
+// in the order of Aggregations.
+//
+// Groups are reported in the order they are first seen, or in no particular
+// order once they have been spilled to disk.
+func (g *GroupBy) Aggregate(r io.Reader, jsPath string, fn func(key json.RawMessage, results []*big.Rat) error) (err error) {
+	for _, agg := range g.Aggregations {
+		if agg < Count || agg > Avg {
+			return errUnknownAggregation
+		}
+	}
+	gr := &grouper{GroupBy: g, groups: map[string]*group{}}
+	if gr.key, err = relativePath(g.Key); err != nil {
+		return err
+	}
+	if gr.value, err = relativePath(g.Value); err != nil {
+		return err
+	}
+	if g.MaxGroups > 0 {
+		gr.known = map[string]bool{}
+	}
+	defer gr.close()
+	if err := NewStreamDecoder(r, g.Options...).DecodePath(jsPath, gr.add); err != nil {
+		return err
+	}
+	return gr.report(fn)
+}
+
+func relativePath(jsPath string) ([]string, error) {
+	if jsPath == "" {
+		return nil, nil
+	}
+	names, err := splitMemberPath(jsPath)
+	if err != nil {
+		return nil, errGroupPath
+	}
+	return names, nil
+}
+
+func (gr *grouper) add(_ []byte, message json.RawMessage) error {
+	key := memberValue(message, gr.key)
+	if key == nil {
+		key = []byte("null")
+	}
+	if gr.known != nil && !gr.known[string(key)] {
+		if len(gr.known) >= gr.MaxGroups {
+			if gr.Other == nil {
+				return ErrTooManyGroups
+			}
+			key = gr.Other
+		} else {
+			gr.known[string(key)] = true
+		}
+	}
+	grp, ok := gr.groups[string(key)]
+	if !ok {
+		if gr.SpillLimit > 0 && len(gr.groups) >= gr.SpillLimit {
+			if err := gr.spill(); err != nil {
+				return err
+			}
+		}
+		grp = &group{key: append([]byte(nil), key...)}
+		gr.groups[string(key)] = grp
+		gr.order = append(gr.order, grp)
+	}
+	if value := memberValue(message, gr.value); value != nil {
+		return grp.agg.add(value)
+	}
+	return nil
+}
+
+// spilledGroup is the record of a group in a spill file.
+type spilledGroup struct {
+	Key     json.RawMessage `json:"key"`
+	Count   int64           `json:"count"`
+	Numbers int64           `json:"numbers"`
+	Sum     string          `json:"sum"`
+	Min     string          `json:"min,omitempty"`
+	Max     string          `json:"max,omitempty"`
+}
+
+// spill moves the groups held in memory to the spill files, partitioned by key.
+func (gr *grouper) spill() error {
+	if gr.spills == nil {
+		for i := 0; i < spillPartitions; i++ {
+			f, err := os.CreateTemp(gr.SpillDir, "jspath-groups-*")
+			if err != nil {
+				return err
+			}
+			gr.spills = append(gr.spills, f)
+		}
+	}
+	writers := make([]*bufio.Writer, len(gr.spills))
+	encoders := make([]*json.Encoder, len(gr.spills))
+	for i := range gr.spills {
+		writers[i] = bufio.NewWriter(gr.spills[i])
+		encoders[i] = json.NewEncoder(writers[i])
+	}
+	for _, grp := range gr.order {
+		rec := spilledGroup{Key: grp.key, Count: grp.agg.count, Numbers: grp.agg.numbers, Sum: grp.agg.total().RatString()}
+		if grp.agg.numbers > 0 {
+			rec.Min, rec.Max = grp.agg.min.RatString(), grp.agg.max.RatString()
+		}
+		if err := encoders[partition(grp.key)].Encode(&rec); err != nil {
+			return err
+		}
+	}
+	for _, w := range writers {
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	gr.groups = map[string]*group{}
+	gr.order = gr.order[0:0]
+	return nil
+}
+
+func partition(key []byte) int {
+	h := fnv.New32a()
+	h.Write(key)
+	return int(h.Sum32() % spillPartitions)
+}
+
+func (gr *grouper) report(fn func(key json.RawMessage, results []*big.Rat) error) error {
+	if gr.spills == nil {
+		return gr.reportGroups(fn)
+	}
+	if err := gr.spill(); err != nil {
+		return err
+	}
+	for _, f := range gr.spills {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		dec := json.NewDecoder(bufio.NewReader(f))
+		for {
+			var rec spilledGroup
+			if err := dec.Decode(&rec); err == io.EOF {
+				break
+			} else if err != nil {
+				return err
+			}
+			var agg aggregator
+			if err := agg.restore(&rec); err != nil {
+				return err
+			}
+			grp, ok := gr.groups[string(rec.Key)]
+			if !ok {
+				grp = &group{key: rec.Key}
+				gr.groups[string(rec.Key)] = grp
+				gr.order = append(gr.order, grp)
+			}
+			grp.agg.merge(&agg)
+		}
+		if err := gr.reportGroups(fn); err != nil {
+			return err
+		}
+		gr.groups = map[string]*group{}
+		gr.order = gr.order[0:0]
+	}
+	return nil
+}
+
+func (gr *grouper) reportGroups(fn func(key json.RawMessage, results []*big.Rat) error) error {
+	for _, grp := range gr.order {
+		results := make([]*big.Rat, len(gr.Aggregations))
+		for i, agg := range gr.Aggregations {
+			results[i] = grp.agg.result(agg)
+		}
+		if err := fn(grp.key, results); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (gr *grouper) close() {
+	for _, f := range gr.spills {
+		f.Close()
+		os.Remove(f.Name())
+	}
+}
+
+// restore sets the aggregator to the state of a spilled group.
+func (a *aggregator) restore(rec *spilledGroup) error {
+	a.count, a.numbers = rec.Count, rec.Numbers
+	if _, ok := a.sum.SetString(rec.Sum); !ok {
+		return &SyntaxError{msg: "invalid spilled sum " + rec.Sum}
+	}
+	if a.numbers == 0 {
+		return nil
+	}
+	if _, ok := a.min.SetString(rec.Min); !ok {
+		return &SyntaxError{msg: "invalid spilled min " + rec.Min}
+	}
+	if _, ok := a.max.SetString(rec.Max); !ok {
+		return &SyntaxError{msg: "invalid spilled max " + rec.Max}
+	}
+	return nil
+}
+
+// merge adds the values aggregated by o to a.
+func (a *aggregator) merge(o *aggregator) {
+	a.sum.Add(&a.sum, o.total())
+	if o.numbers > 0 {
+		if a.numbers == 0 || o.min.Cmp(&a.min) < 0 {
+			a.min.Set(&o.min)
+		}
+		if a.numbers == 0 || o.max.Cmp(&a.max) > 0 {
+			a.max.Set(&o.max)
+		}
+	}
+	a.count += o.count
+	a.numbers += o.numbers
+}
+
+// memberValue returns the raw value at the member names of value, nil when missing.
+func memberValue(value []byte, names []string) []byte {
+	for _, name := range names {
+		if value = objectMember(value, name); value == nil {
+			return nil
+		}
+	}
+	return value
+}
+
+// objectMember returns the raw value of the member name of the raw object, nil when missing.
+// Keys are compared unescaped, as in paths.
+func objectMember(object []byte, name string) []byte {
+	i := skipSpace(object, 0)
+	if i >= len(object) || object[i] != '{' {
+		return nil
+	}
+	i = skipSpace(object, i+1)
+	for i < len(object) && object[i] == '"' {
+		n := valueLen(object[i:])
+		key := object[i+1 : i+n-1]
+		i = skipSpace(object, skipSpace(object, i+n)+1)
+		n = valueLen(object[i:])
+		if bytes.IndexByte(key, '\\') >= 0 {
+			key = appendUnescaped(nil, key)
+		}
+		if BytesToString(key) == name {
+			return object[i : i+n]
+		}
+		if i = skipSpace(object, i+n); i < len(object) && object[i] == ',' {
+			i = skipSpace(object, i+1)
+		}
+	}
+	return nil
+}
+
+func skipSpace(data []byte, i int) int {
+	for i < len(data) && isSpace(data[i]) {
+		i++
+	}
+	return i
+}
+
+// scanners holds the scanners of valueLen, it runs without allocating.
+var scanners = sync.Pool{New: func() interface{} { return new(scanner) }}
+
+// valueLen returns the length of the valid JSON value data starts with.
+func valueLen(data []byte) int {
+	scan := scanners.Get().(*scanner)
+	defer scanners.Put(scan)
+	scan.reset()
+	for i, c := range data {
+		v := scan.step(scan, c)
+		if v == scanEnd {
+			return i
+		}
+		if (v == scanEndObject || v == scanEndArray) && scan.step(scan, ' ') == scanEnd {
+			return i + 1
+		}
+	}
+	return len(data)
+}
@@ -0,0 +1,112 @@
+package jspath
+
+import (
+	"encoding/json"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupBy(t *testing.T) {
+	var testcases = []struct {
+		name    string
+		input   string
+		path    string
+		groupBy GroupBy
+		want    []string
+	}{
+		{
+			name:    "sum of price by category",
+			input:   testdata,
+			path:    "$.store.book[*]",
+			groupBy: GroupBy{Key: "$.category", Value: "$.price", Aggregations: []Aggregation{Count, Sum, Max}},
+			want:    []string{`"reference": 1 8.95 8.95`, `"fiction": 3 44.97 22.99`},
+		},
+		{
+			name:    "missing key",
+			input:   `[{"a": "x", "v": 1}, {"v": 2}, {"a": "x", "v": 3}, {"a": {"b": 1}}]`,
+			path:    "$.[*]",
+			groupBy: GroupBy{Key: "$.a", Value: "$.v", Aggregations: []Aggregation{Count, Avg}},
+			want:    []string{`"x": 2 2`, `null: 1 2`, `{"b": 1}: 0 <nil>`},
+		},
+		{
+			name:    "nested key and whole value",
+			input:   `[{"k": {"id": 1}, "n": 2}, {"k": {"id": 1}}]`,
+			path:    "$.[*].n",
+			groupBy: GroupBy{Key: "$.id", Aggregations: []Aggregation{Sum}},
+			want:    []string{`null: 2`},
+		},
+		{
+			name:    "other group",
+			input:   `[{"a": 1}, {"a": 2}, {"a": 3}, {"a": 1}, {"a": 4}]`,
+			path:    "$.[*]",
+			groupBy: GroupBy{Key: "$.a", Value: "$.a", Aggregations: []Aggregation{Sum}, MaxGroups: 2, Other: json.RawMessage(`"other"`)},
+			want:    []string{`1: 2`, `2: 2`, `"other": 7`},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			require.NoError(t, tc.groupBy.Aggregate(strings.NewReader(tc.input), tc.path, func(key json.RawMessage, results []*big.Rat) error {
+				got = append(got, formatGroup(key, results))
+				return nil
+			}))
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func formatGroup(key json.RawMessage, results []*big.Rat) string {
+	s := string(key) + ":"
+	for _, r := range results {
+		if r == nil {
+			s += " <nil>"
+			continue
+		}
+		s += " " + strings.TrimRight(strings.TrimRight(r.FloatString(10), "0"), ".")
+	}
+	return s
+}
+
+func TestGroupBySpill(t *testing.T) {
+	var input strings.Builder
+	input.WriteString("[")
+	for i := 0; i < 1000; i++ {
+		if i > 0 {
+			input.WriteString(",")
+		}
+		input.WriteString(`{"k": ` + strconv.Itoa(i%100) + `, "v": 0.5}`)
+	}
+	input.WriteString("]")
+
+	dir := t.TempDir()
+	var got []string
+	groupBy := GroupBy{Key: "$.k", Value: "$.v", Aggregations: []Aggregation{Count, Sum, Min}, SpillLimit: 10, SpillDir: dir}
+	require.NoError(t, groupBy.Aggregate(strings.NewReader(input.String()), "$.[*]", func(key json.RawMessage, results []*big.Rat) error {
+		got = append(got, formatGroup(key, results))
+		return nil
+	}))
+	require.Len(t, got, 100)
+	require.Contains(t, got, "0: 10 5 0.5")
+	require.Contains(t, got, "99: 10 5 0.5")
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, files)
+}
+
+func TestGroupByErrors(t *testing.T) {
+	groupBy := GroupBy{Key: "$.a", MaxGroups: 1, Aggregations: []Aggregation{Count}}
+	err := groupBy.Aggregate(strings.NewReader(`[{"a": 1}, {"a": 2}]`), "$.[*]", func(key json.RawMessage, results []*big.Rat) error {
+		return nil
+	})
+	require.Equal(t, ErrTooManyGroups, err)
+
+	groupBy = GroupBy{Key: "$.a[0]"}
+	require.Equal(t, errGroupPath, groupBy.Aggregate(strings.NewReader(`{}`), "$", nil))
+}
@@ -0,0 +1,408 @@
+package jspath
+
+import (
+	"bytes"
+	"io"
+	"math/big"
+	"unicode"
+)
+
+// A Dialect is a relaxed JSON syntax accepted by a lenient StreamDecoder.
+type Dialect int
+
+const (
+	// JSONC allows // and /* */ comments and trailing commas.
+	JSONC Dialect = iota + 1
+	// JSON5 extends JSONC with single quoted strings, unquoted keys, hexadecimal
+	// numbers, leading and trailing decimal points, explicit plus signs,
+	// the JSON5 string escapes and line continuations, and more whitespace.
+	// Infinity and NaN have no JSON representation and are rejected.
+	JSON5
+)
+
+// Lenient makes the StreamDecoder accept the dialect. The input is normalized
+// to strict JSON as it is read, so matched values are handed over as strict JSON.
+//
+// Comments and trailing commas are replaced by spaces, the offsets of JSONC
+// input are preserved. The JSON5 rewrites that change the length of a token,
+// like quoting a key, shift the offsets that follow.
+func Lenient(dialect Dialect) Option {
+	return func(dec *StreamDecoder) {
+		dec.dialect = dialect
+	}
+}
+
+const (
+	lenientValue = iota
+	lenientSlash
+	lenientLineComment
+	lenientBlockComment
+	lenientBlockCommentStar
+	lenientString
+	lenientStringEscape
+	lenientStringHex
+	lenientStringCR
+	lenientToken
+)
+
+// lenientReader normalizes the dialect read from r to strict JSON.
+type lenientReader struct {
+	r       io.Reader
+	dialect Dialect
+	in      []byte
+	out     []byte
+	err     error
+	offset  int64
+
+	state int
+	quote byte
+	hex   []byte
+	token []byte
+
+	// comma is set while a comma is held back until we know whether it is trailing,
+	// held is the output that follows it in the meantime.
+	comma bool
+	held  []byte
+
+	containers []byte
+	expectKey  bool
+	// value is set after a value or a key, the commas must follow one.
+	value bool
+}
+
+func newLenientReader(r io.Reader, dialect Dialect) *lenientReader {
+	return &lenientReader{r: r, dialect: dialect, in: make([]byte, 4096)}
+}
+
+func (l *lenientReader) Read(p []byte) (int, error) {
+	for len(l.out) == 0 && l.err == nil {
+		n, err := l.r.Read(l.in)
+		for _, c := range l.in[:n] {
+			if serr := l.step(c); serr != nil {
+				l.err = serr
+				break
+			}
+			l.offset++
+		}
+		if err == io.EOF && l.err == nil {
+			if serr := l.finish(); serr != nil {
+				err = serr
+			}
+		}
+		if l.err == nil {
+			l.err = err
+		}
+	}
+	n := copy(p, l.out)
+	l.out = l.out[n:]
+	if len(l.out) > 0 {
+		return n, nil
+	}
+	l.out = l.out[0:0]
+	return n, l.err
+}
+
+func (l *lenientReader) error(msg string) error {
+	return &SyntaxError{msg: msg, Offset: l.offset}
+}
+
+// emit appends normalized output.
+func (l *lenientReader) emit(b ...byte) {
+	if l.comma {
+		l.held = append(l.held, b...)
+		return
+	}
+	l.out = append(l.out, b...)
+}
+
+// significant releases the held back comma before the significant character c,
+// as a space when c closes a container.
+func (l *lenientReader) significant(c byte) {
+	if !l.comma {
+		return
+	}
+	l.comma = false
+	if c == ']' || c == '}' {
+		l.out = append(l.out, ' ')
+	} else {
+		l.out = append(l.out, ',')
+	}
+	l.out = append(l.out, l.held...)
+	l.held = l.held[0:0]
+}
+
+func (l *lenientReader) step(c byte) error {
+	switch l.state {
+	case lenientSlash:
+		switch c {
+		case '/':
+			l.state = lenientLineComment
+		case '*':
+			l.state = lenientBlockComment
+		default:
+			return l.error("invalid character " + quoteChar(c) + " after /")
+		}
+		l.emit(' ', ' ')
+		return nil
+
+	case lenientLineComment:
+		if c == '\n' || c == '\r' {
+			l.emit(c)
+			l.state = lenientValue
+			return nil
+		}
+		l.emit(' ')
+		return nil
+
+	case lenientBlockComment, lenientBlockCommentStar:
+		if l.state == lenientBlockCommentStar && c == '/' {
+			l.state = lenientValue
+		} else if c == '*' {
+			l.state = lenientBlockCommentStar
+		} else {
+			l.state = lenientBlockComment
+		}
+		if c == '\n' || c == '\r' {
+			l.emit(c)
+		} else {
+			l.emit(' ')
+		}
+		return nil
+
+	case lenientString:
+		switch {
+		case c == l.quote:
+			l.emit('"')
+			l.state = lenientValue
+		case c == '\\':
+			l.state = lenientStringEscape
+		case c == '"':
+			l.emit('\\', '"')
+		default:
+			l.emit(c)
+		}
+		return nil
+
+	case lenientStringEscape:
+		l.state = lenientString
+		if l.dialect != JSON5 {
+			l.emit('\\', c)
+			return nil
+		}
+		switch c {
+		case '"', '\\', '/', 'b', 'f', 'n', 'r', 't', 'u':
+			l.emit('\\', c)
+		case '\'':
+			l.emit('\'')
+		case 'v':
+			l.emit('\\', 'u', '0', '0', '0', 'b')
+		case '0':
+			l.emit('\\', 'u', '0', '0', '0', '0')
+		case 'x':
+			l.hex = l.hex[0:0]
+			l.state = lenientStringHex
+		case '\n':
+		case '\r':
+			l.state = lenientStringCR
+		default:
+			if c >= '1' && c <= '9' {
+				return l.error("invalid escape \\" + string(c) + " in string")
+			}
+			l.emit(c)
+		}
+		return nil
+
+	case lenientStringHex:
+		if !isHex(c) {
+			return l.error("invalid character " + quoteChar(c) + " in \\x escape")
+		}
+		if l.hex = append(l.hex, c); len(l.hex) == 2 {
+			l.emit('\\', 'u', '0', '0', l.hex[0], l.hex[1])
+			l.state = lenientString
+		}
+		return nil
+
+	case lenientStringCR:
+		// the \n of a \r\n line continuation
+		l.state = lenientString
+		if c == '\n' {
+			return nil
+		}
+		return l.step(c)
+
+	case lenientToken:
+		if !isTokenEnd(c) {
+			l.token = append(l.token, c)
+			return nil
+		}
+		if err := l.endToken(); err != nil {
+			return err
+		}
+		l.state = lenientValue
+	}
+
+	// lenientValue
+	switch c {
+	case ' ', '\t', '\n', '\r':
+		l.emit(c)
+	case '\v', '\f':
+		if l.dialect != JSON5 {
+			l.significant(c)
+			l.emit(c)
+			return nil
+		}
+		l.emit(' ')
+	case '/':
+		l.state = lenientSlash
+	case ',':
+		if !l.value {
+			if l.expectKey {
+				return l.error("invalid character ',' looking for beginning of object key string")
+			}
+			return l.error("invalid character ',' looking for beginning of value")
+		}
+		l.significant(c)
+		l.value = false
+		l.comma = true
+		l.expectKey = len(l.containers) > 0 && l.containers[len(l.containers)-1] == '{'
+	case '{', '[':
+		l.significant(c)
+		l.emit(c)
+		l.containers = append(l.containers, c)
+		l.expectKey = c == '{'
+		l.value = false
+	case '}', ']':
+		l.significant(c)
+		l.emit(c)
+		l.value = true
+		if len(l.containers) > 0 {
+			l.containers = l.containers[:len(l.containers)-1]
+		}
+		l.expectKey = false
+	case ':':
+		l.significant(c)
+		l.emit(c)
+		l.expectKey = false
+		l.value = false
+	case '"':
+		l.significant(c)
+		l.emit(c)
+		l.value = true
+		l.quote = c
+		l.state = lenientString
+	case '\'':
+		l.significant(c)
+		l.value = true
+		if l.dialect != JSON5 {
+			l.emit(c)
+			return nil
+		}
+		l.emit('"')
+		l.quote = c
+		l.state = lenientString
+	default:
+		l.token = append(l.token[0:0], c)
+		l.state = lenientToken
+		l.value = true
+	}
+	return nil
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func isTokenEnd(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\r', '\v', '\f', '/', ',', ':', '{', '}', '[', ']', '"', '\'':
+		return true
+	}
+	return false
+}
+
+// endToken normalizes a literal, a number or an unquoted key.
+func (l *lenientReader) endToken() error {
+	token := l.token
+	if l.dialect != JSON5 {
+		l.significant(token[0])
+		l.emit(token...)
+		return nil
+	}
+	// unicode whitespace around the token
+	lead := len(token) - len(bytes.TrimLeftFunc(token, isJSON5Space))
+	trail := len(token) - len(bytes.TrimRightFunc(token, isJSON5Space))
+	for i := 0; i < lead; i++ {
+		l.emit(' ')
+	}
+	if lead == len(token) {
+		return nil
+	}
+	token = token[lead : len(token)-trail]
+	l.significant(token[0])
+	if l.expectKey {
+		l.emit('"')
+		l.emit(token...)
+		l.emit('"')
+	} else if err := l.number(token); err != nil {
+		return err
+	}
+	for i := 0; i < trail; i++ {
+		l.emit(' ')
+	}
+	return nil
+}
+
+func isJSON5Space(r rune) bool {
+	return unicode.IsSpace(r) || r == '\uFEFF'
+}
+
+// number normalizes the value token, literals are left to the decoder.
+func (l *lenientReader) number(token []byte) error {
+	switch string(token) {
+	case "Infinity", "+Infinity", "-Infinity", "NaN", "+NaN", "-NaN":
+		return l.error(string(token) + " cannot be represented in JSON")
+	}
+	sign := token[0]
+	if sign == '+' || sign == '-' {
+		token = token[1:]
+	}
+	if sign == '-' {
+		l.emit('-')
+	}
+	if len(token) > 2 && token[0] == '0' && (token[1] == 'x' || token[1] == 'X') {
+		var n big.Int
+		if _, ok := n.SetString(string(token[2:]), 16); !ok {
+			return l.error("invalid hexadecimal number " + string(token))
+		}
+		l.emit(n.Append(nil, 10)...)
+		return nil
+	}
+	if len(token) > 0 && token[0] == '.' {
+		l.emit('0')
+	}
+	for i, c := range token {
+		// a trailing decimal point, before the exponent or the end
+		if c == '.' && (i == len(token)-1 || token[i+1] == 'e' || token[i+1] == 'E') {
+			continue
+		}
+		l.emit(c)
+	}
+	return nil
+}
+
+// finish flushes the normalized output at the end of the input.
+func (l *lenientReader) finish() error {
+	switch l.state {
+	case lenientSlash:
+		return l.error("unexpected end of input after /")
+	case lenientBlockComment, lenientBlockCommentStar:
+		return l.error("unterminated comment")
+	case lenientToken:
+		if err := l.endToken(); err != nil {
+			return err
+		}
+	}
+	l.state = lenientValue
+	l.significant(0)
+	return nil
+}
@@ -0,0 +1,152 @@
+package jspath
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLenientReader(t *testing.T) {
+	var testcases = []struct {
+		name    string
+		dialect Dialect
+		input   string
+		want    string
+	}{
+		{
+			name:    "line comments",
+			dialect: JSONC,
+			input:   "{\"a\": 1, // one\n\"b\": \"//\"}",
+			want:    "{\"a\": 1,       \n\"b\": \"//\"}",
+		},
+		{
+			name:    "block comments",
+			dialect: JSONC,
+			input:   "[1 /* a\n*/, 2/**/]",
+			want:    "[1     \n  , 2    ]",
+		},
+		{
+			name:    "trailing commas",
+			dialect: JSONC,
+			input:   `{"a": [1, 2, /* c */ ], "b": {"c": 1,},}`,
+			want:    `{"a": [1, 2          ], "b": {"c": 1 } }`,
+		},
+		{
+			name:    "json5 keys and strings",
+			dialect: JSON5,
+			input:   `{unquoted: 'single "quoted"', $key_2: 'it\'s', 'a': "b"}`,
+			want:    `{"unquoted": "single \"quoted\"", "$key_2": "it's", "a": "b"}`,
+		},
+		{
+			name:    "json5 numbers",
+			dialect: JSON5,
+			input:   `[0x1F, -0XFF, +1, .5, 5., -.5e1, 1.e2, 0xFFFFFFFFFFFFFFFFFF]`,
+			want:    `[31, -255, 1, 0.5, 5, -0.5e1, 1e2, 4722366482869645213695]`,
+		},
+		{
+			name:    "json5 escapes",
+			dialect: JSON5,
+			input:   "['\\x41\\v\\0\\q', 'a\\\nb', 'c\\\r\nd']",
+			want:    `["\u0041\u000b\u0000q", "ab", "cd"]`,
+		},
+		{
+			name:    "json5 whitespace",
+			dialect: JSON5,
+			input:   "\uFEFF{\va:\u00a01}",
+			want:    "   { \"a\":  1}",
+		},
+		{
+			name:    "jsonc keeps json5",
+			dialect: JSONC,
+			input:   `{a: 'b'}`,
+			want:    `{a: 'b'}`,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := io.ReadAll(iotest.OneByteReader(newLenientReader(strings.NewReader(tc.input), tc.dialect)))
+			require.NoError(t, err)
+			require.Equal(t, tc.want, string(out))
+		})
+	}
+}
+
+func TestLenientReaderErrors(t *testing.T) {
+	var testcases = []struct {
+		input  string
+		want   string
+		offset int64
+	}{
+		{input: `[1 / 2]`, want: "invalid character ' ' after /", offset: 4},
+		{input: `[1] /* a`, want: "unterminated comment", offset: 8},
+		{input: `[Infinity]`, want: "Infinity cannot be represented in JSON", offset: 9},
+		{input: `{a: NaN}`, want: "NaN cannot be represented in JSON", offset: 7},
+		{input: `['\1']`, want: `invalid escape \1 in string`, offset: 3},
+		{input: `['\xZZ']`, want: "invalid character 'Z' in \\x escape", offset: 4},
+		{input: `[0xZZ]`, want: "invalid hexadecimal number 0xZZ", offset: 5},
+		{input: `[1,,2]`, want: "invalid character ',' looking for beginning of value", offset: 3},
+		{input: `[,]`, want: "invalid character ',' looking for beginning of value", offset: 1},
+		{input: `{a: 1, /* b */ ,}`, want: "invalid character ',' looking for beginning of object key string", offset: 15},
+	}
+	for _, tc := range testcases {
+		_, err := io.ReadAll(newLenientReader(strings.NewReader(tc.input), JSON5))
+		var serr *SyntaxError
+		require.True(t, errors.As(err, &serr), tc.input)
+		require.Equal(t, tc.want, serr.Error(), tc.input)
+		require.Equal(t, tc.offset, serr.Offset, tc.input)
+	}
+
+	// the decoder reports the inputs the dialect does not fix
+	for _, tc := range []struct {
+		input  string
+		want   string
+		offset int64
+	}{
+		{input: `[1,,2]`, want: "invalid character ',' looking for beginning of value", offset: 3},
+		{input: `{"a": 1 /* c */ "b": 2}`, want: `invalid character '"' after object key:value pair`, offset: 16},
+		{input: `{,}`, want: "invalid character ',' looking for beginning of object key string", offset: 1},
+	} {
+		err := NewStreamDecoder(strings.NewReader(tc.input), Lenient(JSONC)).DecodePath("$.a", func(key []byte, message json.RawMessage) error {
+			return nil
+		})
+		var serr *SyntaxError
+		require.True(t, errors.As(err, &serr), tc.input)
+		require.Equal(t, tc.want, serr.Error(), tc.input)
+		require.Equal(t, tc.offset, serr.Offset, tc.input)
+	}
+}
+
+func TestDecodePathLenient(t *testing.T) {
+	input := `// vendor feed
+{
+	store: {
+		book: [
+			{title: 'Sayings of the Century', price: 8.95,},
+			/* out of print */
+			{title: "Sword of Honour", price: .99, tags: ['a', 'b',],},
+		],
+	},
+}`
+	var got []string
+	dec := NewStreamDecoder(strings.NewReader(input), Lenient(JSON5))
+	require.NoError(t, dec.DecodePath("$.store.book[*]", func(key []byte, message json.RawMessage) error {
+		require.True(t, json.Valid(message), string(message))
+		got = append(got, string(key)+" "+string(message))
+		return nil
+	}))
+	require.Equal(t, []string{
+		`$.store.book[0] {"title": "Sayings of the Century", "price": 8.95 }`,
+		`$.store.book[1] {"title": "Sword of Honour", "price": 0.99, "tags": ["a", "b" ] }`,
+	}, got)
+
+	err := NewStreamDecoder(strings.NewReader(input)).DecodePath("$.store", func(key []byte, message json.RawMessage) error {
+		return nil
+	})
+	require.Error(t, err)
+}
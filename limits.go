@@ -0,0 +1,118 @@
+package jspath
+
+import "fmt"
+
+// Limits bounds the resources a StreamDecoder spends on its input,
+// a zero field means no limit.
+type Limits struct {
+	// MaxDepth is the maximum nesting depth of objects and arrays.
+	MaxDepth int
+	// MaxValueSize is the maximum size in bytes of a value read whole: the matched
+	// values, and the strings, numbers and keys. It bounds the decoder buffer.
+	MaxValueSize int
+	// MaxKeyLength is the maximum size in bytes of an encoded object key.
+	MaxKeyLength int
+	// MaxStringLength is the maximum size in bytes of an encoded string.
+	MaxStringLength int
+	// MaxTotalBytes is the maximum size of the input, after decompression.
+	MaxTotalBytes int64
+	// MaxMembers is the maximum number of members of an object.
+	MaxMembers int
+}
+
+// A LimitError is returned when the input exceeds one of the Limits.
+type LimitError struct {
+	// Limit is the name of the exceeded Limits field, Max its value.
+	Limit string
+	Max   int64
+	// Path is the path of the value being decoded.
+	Path   string
+	Offset int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("jspath: %s at offset %d: %s of %d exceeded", e.Path, e.Offset, e.Limit, e.Max)
+}
+
+// WithLimits makes the StreamDecoder fail with a *LimitError when the input exceeds limits.
+// Matched values are checked before they are handed over. MessagePack and CBOR
+// input is checked as well, on its maps, arrays and strings.
+func WithLimits(limits Limits) Option {
+	return func(dec *StreamDecoder) {
+		dec.limits = limits
+		if limits.MaxDepth > 0 || limits.MaxKeyLength > 0 || limits.MaxStringLength > 0 || limits.MaxMembers > 0 {
+			dec.checkers = append(dec.checkers, &limitChecker{limits: limits})
+		}
+	}
+}
+
+func (dec *StreamDecoder) limitError(limit string, max int64) error {
+	return &LimitError{Limit: limit, Max: max, Path: string(dec.path.PathBytes()), Offset: dec.offset()}
+}
+
+// checkValueSize fails when a value of n bytes exceeds MaxValueSize.
+func (dec *StreamDecoder) checkValueSize(n int) error {
+	if dec.limits.MaxValueSize > 0 && n > dec.limits.MaxValueSize {
+		return dec.limitError("MaxValueSize", int64(dec.limits.MaxValueSize))
+	}
+	return nil
+}
+
+// limitChecker enforces the structural limits, it counts the members of the
+// open objects, -1 for arrays.
+type limitChecker struct {
+	limits  Limits
+	members []int
+}
+
+func (l *limitChecker) open(delim byte, path []byte, offset int64) error {
+	if l.limits.MaxDepth > 0 && len(l.members) >= l.limits.MaxDepth {
+		return &LimitError{Limit: "MaxDepth", Max: int64(l.limits.MaxDepth), Path: string(path), Offset: offset}
+	}
+	members := 0
+	if delim == '[' {
+		members = -1
+	}
+	l.members = append(l.members, members)
+	return nil
+}
+
+func (l *limitChecker) close(delim byte) {
+	if len(l.members) > 0 {
+		l.members = l.members[:len(l.members)-1]
+	}
+}
+
+func (l *limitChecker) member(path []byte, quoted []byte, offset int64) error {
+	if l.limits.MaxKeyLength > 0 && len(quoted)-2 > l.limits.MaxKeyLength {
+		return &LimitError{Limit: "MaxKeyLength", Max: int64(l.limits.MaxKeyLength), Path: string(path), Offset: offset}
+	}
+	top := &l.members[len(l.members)-1]
+	if *top++; l.limits.MaxMembers > 0 && *top > l.limits.MaxMembers {
+		return &LimitError{Limit: "MaxMembers", Max: int64(l.limits.MaxMembers), Path: string(path), Offset: offset}
+	}
+	return nil
+}
+
+func (l *limitChecker) save(cp *Checkpoint) {
+	cp.Members = append([]int(nil), l.members...)
+}
+
+func (l *limitChecker) restore(cp *Checkpoint) error {
+	if len(cp.Members) != len(cp.TokenStack) {
+		return errInvalidCheckpoint
+	}
+	l.members = append(l.members[0:0], cp.Members...)
+	return nil
+}
+
+func (l *limitChecker) reset() {
+	l.members = l.members[0:0]
+}
+
+func (l *limitChecker) str(path []byte, quoted []byte, offset int64) error {
+	if l.limits.MaxStringLength > 0 && len(quoted)-2 > l.limits.MaxStringLength {
+		return &LimitError{Limit: "MaxStringLength", Max: int64(l.limits.MaxStringLength), Path: string(path), Offset: offset}
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package jspath
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodePathLimits(t *testing.T) {
+	var testcases = []struct {
+		name    string
+		limits  Limits
+		input   string
+		path    string
+		limit   string
+		errPath string
+	}{
+		{
+			name:    "depth",
+			limits:  Limits{MaxDepth: 3},
+			input:   `{"a": [{"b": 1}, {"b": [2]}]}`,
+			path:    "$.none",
+			limit:   "MaxDepth",
+			errPath: "$.a[1].b",
+		},
+		{
+			name:    "depth in matched value",
+			limits:  Limits{MaxDepth: 3},
+			input:   `{"a": [{"b": 1}, {"b": [2]}]}`,
+			path:    "$.a",
+			limit:   "MaxDepth",
+			errPath: "$.a[1].b",
+		},
+		{
+			name:    "root array depth",
+			limits:  Limits{MaxDepth: 1},
+			input:   `[[1]]`,
+			path:    "$.none",
+			limit:   "MaxDepth",
+			errPath: "$.[0]",
+		},
+		{
+			name:    "value size",
+			limits:  Limits{MaxValueSize: 16},
+			input:   `{"small": [1, 2], "big": [1, 2, 3, 4, 5, 6, 7, 8, 9]}`,
+			path:    "$.*",
+			limit:   "MaxValueSize",
+			errPath: "$.big",
+		},
+		{
+			name:    "key length",
+			limits:  Limits{MaxKeyLength: 3},
+			input:   `{"abc": {"abcd": 1}}`,
+			path:    "$.none",
+			limit:   "MaxKeyLength",
+			errPath: "$.abc.abcd",
+		},
+		{
+			name:    "string length",
+			limits:  Limits{MaxStringLength: 3},
+			input:   `{"a": ["abc", "abcd"]}`,
+			path:    "$.a",
+			limit:   "MaxStringLength",
+			errPath: "$.a[1]",
+		},
+		{
+			name:    "unmatched string length",
+			limits:  Limits{MaxStringLength: 3},
+			input:   `{"a": ["abc", "abcd"]}`,
+			path:    "$.none",
+			limit:   "MaxStringLength",
+			errPath: "$.a[1]",
+		},
+		{
+			name:    "members",
+			limits:  Limits{MaxMembers: 2},
+			input:   `{"a": {"x": 1, "y": 2}, "b": {"x": 1, "y": 2, "z": 3}}`,
+			path:    "$.none",
+			limit:   "MaxMembers",
+			errPath: "$.b.z",
+		},
+		{
+			name:   "total bytes",
+			limits: Limits{MaxTotalBytes: 20},
+			input:  `{"a": 1} {"a": 2} {"a": 3}`,
+			path:   "$.a",
+			limit:  "MaxTotalBytes",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := NewStreamDecoder(strings.NewReader(tc.input), WithLimits(tc.limits)).DecodePath(tc.path, func(key []byte, message json.RawMessage) error {
+				return nil
+			})
+			var lerr *LimitError
+			require.True(t, errors.As(err, &lerr), "%v", err)
+			require.Equal(t, tc.limit, lerr.Limit)
+			if tc.errPath != "" {
+				require.Equal(t, tc.errPath, lerr.Path)
+			}
+
+			// the input fits without limits
+			require.NoError(t, NewStreamDecoder(strings.NewReader(tc.input)).DecodePath(tc.path, func(key []byte, message json.RawMessage) error {
+				return nil
+			}))
+		})
+	}
+}
+
+func TestDecodePathLimitsWithin(t *testing.T) {
+	input := `{"a": [{"b": 1}, {"b": "xyz"}]} {"a": []}`
+	limits := Limits{MaxDepth: 3, MaxValueSize: 32, MaxKeyLength: 1, MaxStringLength: 3, MaxTotalBytes: int64(len(input)), MaxMembers: 1}
+	var got []string
+	require.NoError(t, NewStreamDecoder(strings.NewReader(input), WithLimits(limits)).DecodePath("$.a[*]", func(key []byte, message json.RawMessage) error {
+		got = append(got, string(key)+" "+string(message))
+		return nil
+	}))
+	require.Equal(t, []string{`$.a[0] {"b": 1}`, `$.a[1] {"b": "xyz"}`}, got)
+}
+
+func TestDecodePathLimitsWhitespace(t *testing.T) {
+	spaces := strings.Repeat(" ", 4<<20)
+	input := `{"a":` + spaces + `1}` + spaces + `{"a": 2}`
+	dec := NewStreamDecoder(strings.NewReader(input), WithLimits(Limits{MaxValueSize: 1024}))
+	var got []string
+	require.NoError(t, dec.DecodePath("$.a", func(key []byte, message json.RawMessage) error {
+		got = append(got, string(message))
+		return nil
+	}))
+	require.Equal(t, []string{"1", "2"}, got)
+	require.Less(t, cap(dec.buf), 1<<16)
+}
+
+func TestDecodePathLimitsDecompressed(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write([]byte(`[` + strings.Repeat(`0,`, 1<<20) + `0]`))
+	require.NoError(t, zw.Close())
+
+	err := NewStreamDecoder(&compressed, AutoDecompress(), WithLimits(Limits{MaxTotalBytes: 1 << 16})).DecodePath("$.none", func(key []byte, message json.RawMessage) error {
+		return nil
+	})
+	var lerr *LimitError
+	require.True(t, errors.As(err, &lerr), "%v", err)
+	require.Equal(t, int64(1<<16), lerr.Max)
+}
+
+func TestDecodePathLongPaths(t *testing.T) {
+	key := strings.Repeat("k", 300)
+	nested := strings.Repeat(`{"a": [`, 300) + `1` + strings.Repeat(`]}`, 300)
+	var testcases = []struct {
+		name   string
+		input  string
+		path   string
+		limits Limits
+		limit  string
+		want   int
+	}{
+		{
+			name:  "long key",
+			input: `{"` + key + `": {"x": 1}}`,
+			path:  "$." + key + ".x",
+			want:  1,
+		},
+		{
+			name:   "long key within the limit",
+			input:  `{"` + key + `": {"x": 1}}`,
+			path:   "$." + key + ".x",
+			limits: Limits{MaxKeyLength: 1000},
+			want:   1,
+		},
+		{
+			name:   "long key over the limit",
+			input:  `{"` + key + `": {"x": 1}}`,
+			path:   "$.*",
+			limits: Limits{MaxKeyLength: 100},
+			limit:  "MaxKeyLength",
+		},
+		{
+			name:  "deep nesting",
+			input: nested,
+			path:  "$" + strings.Repeat(".a[0]", 300),
+			want:  1,
+		},
+		{
+			name:   "deep nesting over the limit",
+			input:  nested,
+			path:   "$.none",
+			limits: Limits{MaxDepth: 100},
+			limit:  "MaxDepth",
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := 0
+			err := NewStreamDecoder(strings.NewReader(tc.input), WithLimits(tc.limits)).DecodePath(tc.path, func(key []byte, message json.RawMessage) error {
+				got++
+				return nil
+			})
+			if tc.limit != "" {
+				var lerr *LimitError
+				require.True(t, errors.As(err, &lerr), "%v", err)
+				require.Equal(t, tc.limit, lerr.Limit)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
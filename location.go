@@ -0,0 +1,146 @@
+package jspath
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// A Segment is a step of the path of a value, a member name or an array index.
+type Segment struct {
+	// Name is the unescaped name of the member, nil for array elements.
+	Name []byte
+	// Index is the index of the array element, -1 for members.
+	Index int
+}
+
+// A Location is the path of a value as typed segments, outermost first, one per
+// container around the value. The top-level values have an empty location. The
+// names of a Location handed to a handler are only valid until it returns.
+type Location []Segment
+
+// Depth returns the number of containers around the value.
+func (l Location) Depth() int {
+	return len(l)
+}
+
+// Index returns the array index of the segment at depth, -1 when it is a member
+// or out of range.
+func (l Location) Index(depth int) int {
+	if depth < 0 || depth >= len(l) {
+		return -1
+	}
+	return l[depth].Index
+}
+
+// Name returns the member name of the segment at depth, nil when it is an array
+// element or out of range.
+func (l Location) Name(depth int) []byte {
+	if depth < 0 || depth >= len(l) {
+		return nil
+	}
+	return l[depth].Name
+}
+
+// JSONPath returns the normalized JSONPath of the location, as defined by RFC 9535:
+// $['store']['book'][0]. Unlike the paths matched by the decoder, it is unambiguous
+// for any member name.
+func (l Location) JSONPath() string {
+	var sb strings.Builder
+	sb.WriteByte('$')
+	for _, s := range l {
+		if s.Name == nil {
+			sb.WriteByte('[')
+			sb.WriteString(strconv.Itoa(s.Index))
+			sb.WriteByte(']')
+			continue
+		}
+		sb.WriteString("['")
+		for i := 0; i < len(s.Name); {
+			r, size := utf8.DecodeRune(s.Name[i:])
+			switch {
+			case r == '\'' || r == '\\':
+				sb.WriteByte('\\')
+				sb.WriteRune(r)
+			case r == '\b':
+				sb.WriteString(`\b`)
+			case r == '\f':
+				sb.WriteString(`\f`)
+			case r == '\n':
+				sb.WriteString(`\n`)
+			case r == '\r':
+				sb.WriteString(`\r`)
+			case r == '\t':
+				sb.WriteString(`\t`)
+			case r < 0x20:
+				sb.WriteString(`\u00`)
+				sb.WriteByte(hexDigits[r>>4])
+				sb.WriteByte(hexDigits[r&0xf])
+			default:
+				sb.Write(s.Name[i : i+size])
+			}
+			i += size
+		}
+		sb.WriteString("']")
+	}
+	return sb.String()
+}
+
+// JSONPointer returns the JSON Pointer of the location, as defined by RFC 6901:
+// /store/book/0. The top-level values have the empty pointer.
+func (l Location) JSONPointer() string {
+	var sb strings.Builder
+	for _, s := range l {
+		sb.WriteByte('/')
+		if s.Name == nil {
+			sb.WriteString(strconv.Itoa(s.Index))
+			continue
+		}
+		for _, c := range s.Name {
+			switch c {
+			case '~':
+				sb.WriteString("~0")
+			case '/':
+				sb.WriteString("~1")
+			default:
+				sb.WriteByte(c)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// Location returns the location of the value being handled. It is meant to be
+// called from the handlers, the location is only valid until they return.
+func (dec *StreamDecoder) Location() Location {
+	return dec.path.Location()
+}
+
+// Location returns the segments of the current path, in a buffer reused by the
+// next calls.
+func (pb *pathBuilder) Location() Location {
+	return pb.locationAt(len(pb.path))
+}
+
+// locationAt returns the segments of the first n bytes of the current path.
+func (pb *pathBuilder) locationAt(n int) Location {
+	pb.location = pb.location[0:0]
+	end := pb.stackSegmentsSizes[0]
+	for i, size := range pb.stackSegmentsSizes[1:] {
+		if end >= n {
+			break
+		}
+		segment := pb.path[end : end+size]
+		end += size
+		if index := pb.indices[i+1]; index >= 0 {
+			pb.location = append(pb.location, Segment{Index: index})
+			continue
+		}
+		name := segment[0:0]
+		if len(segment) > 0 {
+			name = segment[1:]
+		}
+		pb.location = append(pb.location, Segment{Name: name, Index: -1})
+	}
+	return pb.location
+}
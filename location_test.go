@@ -0,0 +1,138 @@
+package jspath
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocation(t *testing.T) {
+	var testcases = []struct {
+		name  string
+		path  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "members and elements",
+			path:  "$.store.book[*].title",
+			input: testdata,
+			want: []string{
+				`$['store']['book'][0]['title'] /store/book/0/title`,
+				`$['store']['book'][1]['title'] /store/book/1/title`,
+				`$['store']['book'][2]['title'] /store/book/2/title`,
+				`$['store']['book'][3]['title'] /store/book/3/title`,
+			},
+		},
+		{
+			name:  "top level",
+			path:  "$",
+			input: `1 {"a": 2}`,
+			want:  []string{`$ `, `$ `},
+		},
+		{
+			name:  "top level array",
+			path:  "$.[*]",
+			input: `[1, [2]]`,
+			want:  []string{`$[0] /0`, `$[1] /1`},
+		},
+		{
+			name:  "ambiguous names",
+			path:  "$.*",
+			input: `{"a.b": 1, "[0]": 2, "~/": 3, "it's\\": 4, "\t\u0001": 5, "": 6}`,
+			want: []string{
+				`$['a.b'] /a.b`,
+				`$['[0]'] /[0]`,
+				`$['~/'] /~0~1`,
+				`$['it\'s\\'] /it's\`,
+				`$['\t\u0001'] /` + "\t\u0001",
+				`$[''] /`,
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			dec := NewStreamDecoder(strings.NewReader(tc.input))
+			require.NoError(t, dec.DecodePath(tc.path, func(key []byte, message json.RawMessage) error {
+				l := dec.Location()
+				got = append(got, l.JSONPath()+" "+l.JSONPointer())
+				return nil
+			}))
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestLocationAccessors(t *testing.T) {
+	var got []string
+	input := `{"users": [{"orders": [10, 11]}, {"orders": [12]}]}`
+	require.NoError(t, NewStreamDecoder(strings.NewReader(input)).DecodeMatch("$.users[*].orders[*]", func(m *Match, message json.RawMessage) error {
+		l := m.Location
+		require.Equal(t, m.Depth, l.Depth())
+		require.Nil(t, l.Name(1))
+		require.Equal(t, -1, l.Index(0))
+		require.Equal(t, -1, l.Index(-1))
+		require.Equal(t, -1, l.Index(4))
+		require.Nil(t, l.Name(4))
+		got = append(got, fmt.Sprintf("%s %d %s %d %s", l.Name(0), l.Index(1), l.Name(2), l.Index(3), message))
+		return nil
+	}))
+	require.Equal(t, []string{"users 0 orders 0 10", "users 0 orders 1 11", "users 1 orders 0 12"}, got)
+
+	got = got[0:0]
+	dec := NewStreamDecoder(bytes.NewReader(decodeHex(t, testMsgpack)), MessagePack(false))
+	require.NoError(t, dec.DecodeMatch("$.*", func(m *Match, message json.RawMessage) error {
+		got = append(got, m.Location.JSONPointer())
+		return nil
+	}, String))
+	require.Equal(t, []string{"/store/book/0/title", "/store/book/1/title"}, got)
+}
+
+func TestLocationCheckpoint(t *testing.T) {
+	input := `{"a": [{"b": [1, 2, 3]}, [4, 5]]}`
+	errStop := errors.New("stop")
+	var cp Checkpoint
+	dec := NewStreamDecoder(strings.NewReader(input))
+	dec.WithCheckpoint(func(c Checkpoint) error {
+		cp = c
+		return errStop
+	})
+	require.Equal(t, errStop, dec.DecodePath("$.a[*].b[*]", func(key []byte, message json.RawMessage) error {
+		return nil
+	}))
+
+	var got []string
+	dec = NewStreamDecoder(strings.NewReader(input[cp.Offset:]))
+	require.NoError(t, dec.Restore(cp))
+	require.NoError(t, dec.DecodePath("$.a[*]*", func(key []byte, message json.RawMessage) error {
+		got = append(got, dec.Location().JSONPath()+"="+string(message))
+		return nil
+	}))
+	require.Equal(t, []string{
+		"$['a'][0]['b'][1]=2",
+		"$['a'][0]['b'][2]=3",
+		"$['a'][1]=[4, 5]",
+	}, got)
+}
+
+func BenchmarkLocation(b *testing.B) {
+	pb := newPathBuilder()
+	pb.StartObject()
+	pb.SetObjectKey([]byte("users"))
+	pb.StartArray()
+	pb.IncrementArrayIndex()
+	pb.StartObject()
+	pb.SetObjectKey([]byte("orders"))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if l := pb.Location(); l.Index(1) != 1 {
+			b.Fatal(l)
+		}
+	}
+}
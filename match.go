@@ -0,0 +1,121 @@
+package jspath
+
+import (
+	"encoding/json"
+)
+
+// A Match describes a matched value.
+type Match struct {
+	Kind Kind
+	// Path is the path of the value, it is only valid until the handler returns.
+	Path []byte
+	// Offset is the input offset of the value and Length its size in bytes,
+	// in the encoding of the input.
+	Offset int64
+	Length int
+	// Depth is the number of containers around the value.
+	Depth int
+	// Index is the index of the value in its array, -1 for the other values.
+	Index int
+	// Name is the unescaped name of the member holding the value, nil for the
+	// other values. It is only valid until the handler returns.
+	Name []byte
+	// Location is the path of the value as typed segments, it is only valid
+	// until the handler returns.
+	Location Location
+	// Captures holds the segments captured by the named wildcards of the path.
+	Captures Captures
+}
+
+// A MatchUnmarshalerStream is an UnmarshalerStream receiving a description of
+// the matched values, UnmarshalMatch is called instead of UnmarshalStream.
+type MatchUnmarshalerStream interface {
+	UnmarshalerStream
+	// Kinds returns the kinds of the values matched, any kind when empty.
+	// The decoder descends into the containers of the other kinds.
+	Kinds() []Kind
+	// UnmarshalMatch is called once the path is matched, the content of
+	// the message is only valid until the function returns.
+	UnmarshalMatch(m *Match, message json.RawMessage) error
+}
+
+// DecodeMatch is like DecodePath, with a description of the matched values.
+// When kinds are given only the values of these kinds are matched, so
+//
+//	dec.DecodeMatch("$.*", onMatch, jspath.String)
+//
+// matches the strings at any depth.
+func (dec *StreamDecoder) DecodeMatch(jsPath string, onMatch func(m *Match, message json.RawMessage) error, kinds ...Kind) error {
+	d, err := dec.newDecoder(&matchStreamUnmarshaler{matchPath: jsPath, kinds: kinds, onMatch: onMatch})
+	if err != nil {
+		return err
+	}
+	go dec.decode(d)
+	<-dec.Done()
+	return dec.err
+}
+
+type matchStreamUnmarshaler struct {
+	matchPath string
+	kinds     []Kind
+	onMatch   func(m *Match, message json.RawMessage) error
+}
+
+func (u *matchStreamUnmarshaler) AtPath() string {
+	return u.matchPath
+}
+
+func (u *matchStreamUnmarshaler) Kinds() []Kind {
+	return u.kinds
+}
+
+func (u *matchStreamUnmarshaler) UnmarshalStream(key []byte, message json.RawMessage) error {
+	return u.onMatch(&Match{Kind: Value(message).Kind(), Path: key, Length: len(message), Index: -1}, message)
+}
+
+func (u *matchStreamUnmarshaler) UnmarshalMatch(m *Match, message json.RawMessage) error {
+	return u.onMatch(m, message)
+}
+
+// kindSet is a set of kinds, the empty set holds them all.
+type kindSet uint
+
+func newKindSet(kinds []Kind) kindSet {
+	var set kindSet
+	for _, k := range kinds {
+		set |= 1 << uint(k)
+	}
+	return set
+}
+
+func (s kindSet) has(k Kind) bool {
+	return s == 0 || s&(1<<uint(k)) != 0
+}
+
+// literalKind returns the kind of the value starting with c.
+func literalKind(c byte) Kind {
+	switch c {
+	case '{':
+		return Object
+	case '[':
+		return Array
+	case '"':
+		return String
+	case 't', 'f':
+		return Bool
+	case 'n':
+		return Null
+	}
+	return Number
+}
+
+// match fills m with the location of the value at the current path.
+func (pb *pathBuilder) match(m *Match) {
+	m.Path = pb.path
+	m.Location = pb.Location()
+	m.Depth = len(m.Location)
+	m.Index, m.Name = -1, nil
+	if m.Depth > 0 {
+		m.Index, m.Name = m.Location[m.Depth-1].Index, m.Location[m.Depth-1].Name
+	}
+}
@@ -0,0 +1,126 @@
+package jspath
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func formatMatch(m *Match, message json.RawMessage) string {
+	return fmt.Sprintf("%s %s offset=%d length=%d depth=%d index=%d name=%q %s", m.Path, m.Kind, m.Offset, m.Length, m.Depth, m.Index, m.Name, message)
+}
+
+func TestDecodeMatch(t *testing.T) {
+	input := `{"a": [1, "two", {"b": null}], "c": true} [[3]]`
+	var testcases = []struct {
+		name  string
+		path  string
+		kinds []Kind
+		want  []string
+	}{
+		{
+			name: "elements",
+			path: "$.a[*]",
+			want: []string{
+				`$.a[0] number offset=7 length=1 depth=2 index=0 name="" 1`,
+				`$.a[1] string offset=10 length=5 depth=2 index=1 name="" "two"`,
+				`$.a[2] object offset=17 length=11 depth=2 index=2 name="" {"b": null}`,
+			},
+		},
+		{
+			name: "members",
+			path: "$.*",
+			want: []string{
+				`$.a array offset=6 length=23 depth=1 index=-1 name="a" [1, "two", {"b": null}]`,
+				`$.c boolean offset=36 length=4 depth=1 index=-1 name="c" true`,
+				`$.[0] array offset=43 length=3 depth=1 index=0 name="" [3]`,
+			},
+		},
+		{
+			name: "top level",
+			path: "$",
+			want: []string{
+				`$ object offset=0 length=41 depth=0 index=-1 name="" {"a": [1, "two", {"b": null}], "c": true}`,
+				`$ array offset=42 length=5 depth=0 index=-1 name="" [[3]]`,
+			},
+		},
+		{
+			name:  "scalars at any depth",
+			path:  "$*",
+			kinds: []Kind{Number, Null},
+			want: []string{
+				`$.a[0] number offset=7 length=1 depth=2 index=0 name="" 1`,
+				`$.a[2].b null offset=23 length=4 depth=3 index=-1 name="b" null`,
+				`$.[0][0] number offset=44 length=1 depth=2 index=0 name="" 3`,
+			},
+		},
+		{
+			name:  "containers",
+			path:  "$.*",
+			kinds: []Kind{Object, Bool},
+			want: []string{
+				`$.a[2] object offset=17 length=11 depth=2 index=2 name="" {"b": null}`,
+				`$.c boolean offset=36 length=4 depth=1 index=-1 name="c" true`,
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			require.NoError(t, NewStreamDecoder(strings.NewReader(input)).DecodeMatch(tc.path, func(m *Match, message json.RawMessage) error {
+				require.Equal(t, string(message), string(input[m.Offset:m.Offset+int64(m.Length)]))
+				got = append(got, formatMatch(m, message))
+				return nil
+			}, tc.kinds...))
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestDecodeMatchBinary(t *testing.T) {
+	var got []string
+	dec := NewStreamDecoder(bytes.NewReader(decodeHex(t, testMsgpack)), MessagePack(false))
+	require.NoError(t, dec.DecodeMatch("$.*", func(m *Match, message json.RawMessage) error {
+		got = append(got, formatMatch(m, message))
+		return nil
+	}, Number, Bool))
+	require.Equal(t, []string{
+		`$.store.book[0].price number offset=29 length=9 depth=4 index=-1 name="price" 8.95`,
+		`$.store.book[1].price number offset=53 length=1 depth=4 index=-1 name="price" 12`,
+		`$.1 boolean offset=58 length=1 depth=1 index=-1 name="1" true`,
+		`$.[0] number offset=60 length=1 depth=1 index=0 name="" 1`,
+		`$.[1] number offset=61 length=2 depth=1 index=1 name="" -1`,
+		`$.[2] number offset=63 length=3 depth=1 index=2 name="" 256`,
+	}, got)
+
+	got = got[0:0]
+	dec = NewStreamDecoder(bytes.NewReader(decodeHex(t, testCBOR)), CBOR(true))
+	require.NoError(t, dec.DecodeMatch("$.*", func(m *Match, message json.RawMessage) error {
+		got = append(got, fmt.Sprintf("%s %s %x", m.Path, m.Kind, []byte(message)))
+		return nil
+	}, Number))
+	require.Equal(t, []string{
+		`$.readings[0].t number c11a6553f100`,
+		`$.readings[0].v number f94d60`,
+		`$.readings[1].t number 1a6553f13c`,
+		`$.readings[1].v number 22`,
+		`$.[0] number c249010000000000000000`,
+		`$.[1] number c349010000000000000000`,
+		`$.[2] number 3bffffffffffffffff`,
+		`$.[6] number fa3fc00000`,
+	}, got)
+}
+
+func TestDecodeMatchEmbedded(t *testing.T) {
+	var got []string
+	input := `{"m": "{\"a\": [true]}"}`
+	require.NoError(t, NewStreamDecoder(strings.NewReader(input)).DecodeMatch("$.m|json.a[*]", func(m *Match, message json.RawMessage) error {
+		got = append(got, formatMatch(m, message))
+		return nil
+	}))
+	require.Equal(t, []string{`$.m|json.a[0] boolean offset=7 length=4 depth=2 index=0 name="" true`}, got)
+}
@@ -0,0 +1,188 @@
+package jspath
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// ApplyMergePatch streams the document read from r to w merging it with the
+// JSON Merge Patch (RFC 7396) patch.
+//
+// Only the patch is held in memory. The members of the document are kept in
+// their order, the members the patch adds are written at the end of their object.
+// The opts configure the underlying StreamRewriter.
+func ApplyMergePatch(r io.Reader, w io.Writer, patch json.RawMessage, opts ...Option) error {
+	m := &merger{patch: bytes.TrimSpace(patch)}
+	if len(m.patch) > 0 && m.patch[0] == '{' {
+		var err error
+		if m.root, err = parseMergeNode(m.patch); err != nil {
+			return err
+		}
+	} else if !json.Valid(m.patch) {
+		return &SyntaxError{msg: "invalid merge patch"}
+	}
+	rw := NewStreamRewriter(r, w, opts...)
+	m.path = &rw.dec.path
+	return rw.run(m)
+}
+
+// A mergeNode is an object of the merge patch.
+type mergeNode struct {
+	keys []string
+	// values holds the members values, objects without their null members.
+	values   map[string]json.RawMessage
+	children map[string]*mergeNode
+	// seen records the members found in the object being merged.
+	seen map[string]bool
+}
+
+func parseMergeNode(data []byte) (*mergeNode, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	node := &mergeNode{values: map[string]json.RawMessage{}, children: map[string]*mergeNode{}, seen: map[string]bool{}}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key := t.(string)
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		if _, ok := node.values[key]; !ok {
+			node.keys = append(node.keys, key)
+		}
+		node.values[key] = value
+		delete(node.children, key)
+		if value[0] == '{' {
+			child, err := parseMergeNode(value)
+			if err != nil {
+				return nil, err
+			}
+			node.children[key] = child
+			node.values[key] = child.object()
+		}
+	}
+	return node, nil
+}
+
+// object returns the node as an object without its null members,
+// what merging it with a non object gives.
+func (node *mergeNode) object() json.RawMessage {
+	out := []byte{'{'}
+	for _, key := range node.keys {
+		value := node.values[key]
+		if isNull(value) {
+			continue
+		}
+		if len(out) > 1 {
+			out = append(out, ',')
+		}
+		k, _ := json.Marshal(key)
+		out = append(append(append(out, k...), ':'), value...)
+	}
+	return append(out, '}')
+}
+
+func isNull(value json.RawMessage) bool {
+	return len(value) == 4 && string(value) == "null"
+}
+
+// merger is the rewriteHandler applying a merge patch.
+type merger struct {
+	patch json.RawMessage
+	// root is the patch when it is an object.
+	root *mergeNode
+	// path is the path of the rewriter, the members are looked up by its segments.
+	path *pathBuilder
+}
+
+func (m *merger) rewrite(path []byte, c byte) (RewriteFunc, error) {
+	if m.root == nil {
+		return m.replace(m.patch), nil
+	}
+	if len(path) == 1 {
+		if c == '{' {
+			return nil, nil
+		}
+		return m.replace(m.root.object()), nil
+	}
+	node, key := m.lookup(path)
+	if node == nil {
+		return nil, nil
+	}
+	value, ok := node.values[string(key)]
+	if !ok {
+		return nil, nil
+	}
+	node.seen[string(key)] = true
+	if isNull(value) {
+		return func(key []byte, message json.RawMessage) (json.RawMessage, error) {
+			return nil, Delete
+		}, nil
+	}
+	if node.children[string(key)] != nil && c == '{' {
+		// merged member by member
+		return nil, nil
+	}
+	return m.replace(value), nil
+}
+
+func (m *merger) replace(value json.RawMessage) RewriteFunc {
+	return func(key []byte, message json.RawMessage) (json.RawMessage, error) {
+		return value, nil
+	}
+}
+
+func (m *merger) insertBefore(path []byte) ([]json.RawMessage, error) {
+	return nil, nil
+}
+
+func (m *merger) insertEnd(path []byte, delim byte, members int) ([]rawMember, error) {
+	if m.root == nil || delim != '}' {
+		return nil, nil
+	}
+	node := m.root
+	if len(path) > 1 {
+		parent, key := m.lookup(path)
+		if parent == nil {
+			return nil, nil
+		}
+		if node = parent.children[string(key)]; node == nil {
+			return nil, nil
+		}
+	}
+	var added []rawMember
+	for _, key := range node.keys {
+		if !node.seen[key] && !isNull(node.values[key]) {
+			k, _ := json.Marshal(key)
+			added = append(added, rawMember{key: k, value: node.values[key]})
+		}
+		delete(node.seen, key)
+	}
+	return added, nil
+}
+
+// lookup returns the patch object holding the member at path and its key.
+// Array elements are never merged, they are replaced with their array.
+// The key is only valid until the path changes.
+func (m *merger) lookup(path []byte) (*mergeNode, []byte) {
+	node := m.root
+	location := m.path.locationAt(len(path))
+	for i, segment := range location {
+		if segment.Name == nil {
+			return nil, nil
+		}
+		if i == len(location)-1 {
+			return node, segment.Name
+		}
+		if node = node.children[string(segment.Name)]; node == nil {
+			return nil, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,157 @@
+package jspath
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyMergePatch(t *testing.T) {
+	var testcases = []struct {
+		name  string
+		input string
+		patch string
+		want  string
+	}{
+		{
+			name:  "replace member",
+			input: `{"a":"b"}`,
+			patch: `{"a":"c"}`,
+			want:  `{"a":"c"}`,
+		},
+		{
+			name:  "add member",
+			input: `{"a":"b"}`,
+			patch: `{"b":"c"}`,
+			want:  `{"a":"b","b":"c"}`,
+		},
+		{
+			name:  "remove member",
+			input: `{"a":"b"}`,
+			patch: `{"a":null}`,
+			want:  `{}`,
+		},
+		{
+			name:  "remove one of two members",
+			input: `{"a":"b","b":"c"}`,
+			patch: `{"a":null}`,
+			want:  `{"b":"c"}`,
+		},
+		{
+			name:  "replace array",
+			input: `{"a":["b"]}`,
+			patch: `{"a":"c"}`,
+			want:  `{"a":"c"}`,
+		},
+		{
+			name:  "replace with array",
+			input: `{"a":"c"}`,
+			patch: `{"a":["b"]}`,
+			want:  `{"a":["b"]}`,
+		},
+		{
+			name:  "merge nested object",
+			input: `{"a": {"b": "c"}}`,
+			patch: `{"a": {"b": "d", "c": null}}`,
+			want:  `{"a": {"b": "d"}}`,
+		},
+		{
+			name:  "arrays are replaced",
+			input: `{"a": [{"b":"c"}]}`,
+			patch: `{"a": [1]}`,
+			want:  `{"a": [1]}`,
+		},
+		{
+			name:  "root array",
+			input: `["a","b"]`,
+			patch: `["c","d"]`,
+			want:  `["c","d"]`,
+		},
+		{
+			name:  "object replaces array",
+			input: `["a"]`,
+			patch: `{"a":"b","c":null}`,
+			want:  `{"a":"b"}`,
+		},
+		{
+			name:  "scalar root",
+			input: `{"a":"foo"}`,
+			patch: `"bar"`,
+			want:  `"bar"`,
+		},
+		{
+			name:  "null value",
+			input: `{"e":null}`,
+			patch: `{"a":1}`,
+			want:  `{"e":null,"a":1}`,
+		},
+		{
+			name:  "object replaces scalar",
+			input: `{"a":"b"}`,
+			patch: `{"a":{"b":"c","d":null}}`,
+			want:  `{"a":{"b":"c"}}`,
+		},
+		{
+			name:  "add nested members",
+			input: `{}`,
+			patch: `{"a":{"bb":{"ccc":null}}}`,
+			want:  `{"a":{"bb":{}}}`,
+		},
+		{
+			name:  "missing nested object",
+			input: `{"b": {"c": 1}}`,
+			patch: `{"a": {"c": 2}, "b": {"d": 3}}`,
+			want:  `{"b": {"c": 1,"d":3},"a":{"c":2}}`,
+		},
+		{
+			name: "rfc example",
+			input: `{"title": "Goodbye!", "author": {"givenName": "John", "familyName": "Doe"},
+				"tags": ["example", "sample"], "content": "This will be unchanged"}`,
+			patch: `{"title": "Hello!", "phoneNumber": "+01-123-456-7890", "author": {"familyName": null}, "tags": ["example"]}`,
+			want: `{"title": "Hello!", "author": {"givenName": "John"},
+				"tags": ["example"], "content": "This will be unchanged","phoneNumber":"+01-123-456-7890"}`,
+		},
+		{
+			name:  "keys with dots",
+			input: `{"example.com":1,"x":2}`,
+			patch: `{"example.com":5}`,
+			want:  `{"example.com":5,"x":2}`,
+		},
+		{
+			name:  "keys with brackets",
+			input: `{"a[0]":{"b":1},"a":[{"b":2}]}`,
+			patch: `{"a[0]":{"b":null,"c":3}}`,
+			want:  `{"a[0]":{"c":3},"a":[{"b":2}]}`,
+		},
+		{
+			name:  "nested keys with dots",
+			input: `{"a":{"b.c":1},"a.b":{"c":2}}`,
+			patch: `{"a.b":{"c":3}}`,
+			want:  `{"a":{"b.c":1},"a.b":{"c":3}}`,
+		},
+		{
+			name:  "multiple documents",
+			input: `{"a": 1} {"b": 2}`,
+			patch: `{"a": null, "c": 3}`,
+			want:  `{"c":3} {"b": 2,"c":3}`,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			require.NoError(t, ApplyMergePatch(strings.NewReader(tc.input), &out, json.RawMessage(tc.patch)))
+			require.Equal(t, tc.want, out.String())
+		})
+	}
+}
+
+func TestApplyMergePatchInvalid(t *testing.T) {
+	for _, patch := range []string{``, `{"a":`, `[1,`} {
+		var out bytes.Buffer
+		require.Error(t, ApplyMergePatch(strings.NewReader(`{}`), &out, json.RawMessage(patch)), patch)
+	}
+}
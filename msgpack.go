@@ -0,0 +1,158 @@
+package jspath
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"math"
+	"strconv"
+	"time"
+)
+
+// MessagePack makes the StreamDecoder read a MessagePack stream, a sequence of
+// MessagePack values. Paths and matching work as for JSON, map keys that are
+// not strings are rendered as JSON in paths.
+//
+// Matched values are handed over transcoded to JSON, or as their raw MessagePack
+// encoding when raw is set. Binary data is transcoded to a base64 string, the
+// timestamp extension to an RFC 3339 string and the other extensions to
+// the base64 string of their data.
+func MessagePack(raw bool) Option {
+	return func(dec *StreamDecoder) {
+		dec.format = msgpackFormat{}
+		dec.rawBinary = raw
+	}
+}
+
+var errMsgpackNeverUsed = errors.New("invalid MessagePack format 0xc1")
+
+type msgpackFormat struct{}
+
+func (msgpackFormat) item(data []byte) (binaryItem, error) {
+	if len(data) == 0 {
+		return binaryItem{}, errBinaryShort
+	}
+	c := data[0]
+	switch {
+	case c <= 0x7f || c >= 0xe0:
+		return binaryItem{kind: binaryScalar, head: 1}, nil
+	case c <= 0x8f:
+		return binaryItem{kind: binaryMap, head: 1, length: int(c & 0x0f)}, nil
+	case c <= 0x9f:
+		return binaryItem{kind: binaryArray, head: 1, length: int(c & 0x0f)}, nil
+	case c <= 0xbf:
+		return binaryItem{kind: binaryString, head: 1, length: int(c & 0x1f)}, nil
+	}
+	switch c {
+	case 0xc0, 0xc2, 0xc3:
+		return binaryItem{kind: binaryScalar, head: 1}, nil
+	case 0xc1:
+		return binaryItem{}, errMsgpackNeverUsed
+	case 0xc4, 0xc5, 0xc6:
+		return msgpackSized(data, binaryBytes, 1<<(c-0xc4), 0)
+	case 0xc7, 0xc8, 0xc9:
+		// extension type byte after the size
+		return msgpackSized(data, binaryScalar, 1<<(c-0xc7), 1)
+	case 0xca:
+		return binaryItem{kind: binaryScalar, head: 5}, nil
+	case 0xcb:
+		return binaryItem{kind: binaryScalar, head: 9}, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return binaryItem{kind: binaryScalar, head: 1 + 1<<(c-0xcc)}, nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		return binaryItem{kind: binaryScalar, head: 1 + 1<<(c-0xd0)}, nil
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return binaryItem{kind: binaryScalar, head: 2, length: 1 << (c - 0xd4)}, nil
+	case 0xd9, 0xda, 0xdb:
+		return msgpackSized(data, binaryString, 1<<(c-0xd9), 0)
+	case 0xdc, 0xdd:
+		return msgpackSized(data, binaryArray, 2<<(c-0xdc), 0)
+	}
+	// 0xde, 0xdf
+	return msgpackSized(data, binaryMap, 2<<(c-0xde), 0)
+}
+
+// msgpackSized parses a header holding a big endian size of n bytes followed by extra bytes.
+func msgpackSized(data []byte, kind, n, extra int) (binaryItem, error) {
+	if len(data) < 1+n+extra {
+		return binaryItem{}, errBinaryShort
+	}
+	var length uint64
+	for _, b := range data[1 : 1+n] {
+		length = length<<8 | uint64(b)
+	}
+	if length > math.MaxInt32 {
+		return binaryItem{}, errors.New("MessagePack length overflow")
+	}
+	return binaryItem{kind: kind, head: 1 + n + extra, length: int(length)}, nil
+}
+
+func (msgpackFormat) appendScalar(dst []byte, data []byte) ([]byte, error) {
+	c := data[0]
+	switch {
+	case c <= 0x7f:
+		return strconv.AppendInt(dst, int64(c), 10), nil
+	case c >= 0xe0:
+		return strconv.AppendInt(dst, int64(int8(c)), 10), nil
+	}
+	switch c {
+	case 0xc0:
+		return append(dst, "null"...), nil
+	case 0xc2:
+		return append(dst, "false"...), nil
+	case 0xc3:
+		return append(dst, "true"...), nil
+	case 0xca:
+		return appendJSONFloat(dst, float64(math.Float32frombits(binary.BigEndian.Uint32(data[1:]))), 32)
+	case 0xcb:
+		return appendJSONFloat(dst, math.Float64frombits(binary.BigEndian.Uint64(data[1:])), 64)
+	case 0xcc:
+		return strconv.AppendUint(dst, uint64(data[1]), 10), nil
+	case 0xcd:
+		return strconv.AppendUint(dst, uint64(binary.BigEndian.Uint16(data[1:])), 10), nil
+	case 0xce:
+		return strconv.AppendUint(dst, uint64(binary.BigEndian.Uint32(data[1:])), 10), nil
+	case 0xcf:
+		return strconv.AppendUint(dst, binary.BigEndian.Uint64(data[1:]), 10), nil
+	case 0xd0:
+		return strconv.AppendInt(dst, int64(int8(data[1])), 10), nil
+	case 0xd1:
+		return strconv.AppendInt(dst, int64(int16(binary.BigEndian.Uint16(data[1:]))), 10), nil
+	case 0xd2:
+		return strconv.AppendInt(dst, int64(int32(binary.BigEndian.Uint32(data[1:]))), 10), nil
+	case 0xd3:
+		return strconv.AppendInt(dst, int64(binary.BigEndian.Uint64(data[1:])), 10), nil
+	}
+	// extensions, the type byte is right before the data
+	typ := int8(data[1])
+	ext := data[2:]
+	if c >= 0xc7 && c <= 0xc9 {
+		n := 1 << (c - 0xc7)
+		typ = int8(data[1+n])
+		ext = data[2+n:]
+	}
+	if typ == -1 {
+		if t, ok := msgpackTimestamp(ext); ok {
+			dst = append(dst, '"')
+			dst = t.AppendFormat(dst, time.RFC3339Nano)
+			return append(dst, '"'), nil
+		}
+	}
+	dst = append(dst, '"')
+	dst = append(dst, base64.StdEncoding.EncodeToString(ext)...)
+	return append(dst, '"'), nil
+}
+
+// msgpackTimestamp decodes the data of the timestamp extension.
+func msgpackTimestamp(data []byte) (time.Time, bool) {
+	switch len(data) {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(data)), 0).UTC(), true
+	case 8:
+		v := binary.BigEndian.Uint64(data)
+		return time.Unix(int64(v&(1<<34-1)), int64(v>>34)).UTC(), true
+	case 12:
+		return time.Unix(int64(binary.BigEndian.Uint64(data[4:])), int64(binary.BigEndian.Uint32(data))).UTC(), true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,185 @@
+package jspath
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+// {"store": {"book": [{"title": "a", "price": 8.95}, {"title": "b", "price": 12}], "n": nil}, 1: true} [1, -1, 256]
+var testMsgpack = "82a573746f726582a4626f6f6b9282a57469746c65a161a57072696365cb4021e6666666666682a57469746c65a162a570726963650ca16ec001c3" +
+	"9301d0ffcd0100"
+
+// {"bin": bin(1, 2, 3), "ts": timestamp32(1700000000), "f32": 1.5, "u64": uint64 max, "str8": "q\"\n\xff!", "ext": ext8(5, "ab")}
+var testMsgpackTypes = "de0006a362696ec403010203a27473d6ff6553f100a3663332ca3fc00000a3753634cfffffffffffffffffa473747238d90571220aff21a3657874c702056162"
+
+func decodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
+
+func TestDecodePathMessagePack(t *testing.T) {
+	var testcases = []struct {
+		name  string
+		input string
+		path  string
+		want  []string
+	}{
+		{
+			name:  "array elements",
+			input: testMsgpack,
+			path:  "$.store.book[*]",
+			want:  []string{`$.store.book[0] {"title":"a","price":8.95}`, `$.store.book[1] {"title":"b","price":12}`},
+		},
+		{
+			name:  "member",
+			input: testMsgpack,
+			path:  "$.store.book[1].title",
+			want:  []string{`$.store.book[1].title "b"`},
+		},
+		{
+			name:  "integer key and nil",
+			input: testMsgpack,
+			path:  "$.1",
+			want:  []string{`$.1 true`},
+		},
+		{
+			name:  "second document",
+			input: testMsgpack,
+			path:  "$.[*]",
+			want:  []string{`$.[0] 1`, `$.[1] -1`, `$.[2] 256`},
+		},
+		{
+			name:  "whole documents",
+			input: testMsgpack,
+			path:  "$",
+			want: []string{
+				`$ {"store":{"book":[{"title":"a","price":8.95},{"title":"b","price":12}],"n":null},"1":true}`,
+				`$ [1,-1,256]`,
+			},
+		},
+		{
+			name:  "types",
+			input: testMsgpackTypes,
+			path:  "$",
+			want:  []string{`$ {"bin":"AQID","ts":"2023-11-14T22:13:20Z","f32":1.5,"u64":18446744073709551615,"str8":"q\"\n�!","ext":"YWI="}`},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			dec := NewStreamDecoder(iotest.OneByteReader(bytes.NewReader(decodeHex(t, tc.input))), MessagePack(false))
+			require.NoError(t, dec.DecodePath(tc.path, func(key []byte, message json.RawMessage) error {
+				require.True(t, json.Valid(message), string(message))
+				got = append(got, string(key)+" "+string(message))
+				return nil
+			}))
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestDecodePathMessagePackRaw(t *testing.T) {
+	input := decodeHex(t, testMsgpack)
+	var got [][]byte
+	dec := NewStreamDecoder(bytes.NewReader(input), MessagePack(true))
+	require.NoError(t, dec.DecodePath("$.store.book[*]", func(key []byte, message json.RawMessage) error {
+		got = append(got, append([]byte(nil), message...))
+		return nil
+	}))
+	require.Len(t, got, 2)
+	require.Equal(t, "82a57469746c65a161a57072696365cb4021e66666666666", hex.EncodeToString(got[0]))
+	require.Equal(t, "82a57469746c65a162a570726963650c", hex.EncodeToString(got[1]))
+}
+
+func TestDecodePathMessagePackErrors(t *testing.T) {
+	input := decodeHex(t, testMsgpack)
+	err := NewStreamDecoder(bytes.NewReader(input[:20]), MessagePack(false)).DecodePath("$.none", func(key []byte, message json.RawMessage) error {
+		return nil
+	})
+	require.Equal(t, io.ErrUnexpectedEOF, err)
+
+	err = NewStreamDecoder(bytes.NewReader([]byte{0x91, 0xc1}), MessagePack(false)).DecodePath("$.none", func(key []byte, message json.RawMessage) error {
+		return nil
+	})
+	var serr *SyntaxError
+	require.True(t, errors.As(err, &serr), "%v", err)
+	require.Equal(t, int64(1), serr.Offset)
+
+	err = NewStreamDecoder(bytes.NewReader([]byte{0xcb, 0x7f, 0xf8, 0, 0, 0, 0, 0, 1}), MessagePack(false)).DecodePath("$", func(key []byte, message json.RawMessage) error {
+		return nil
+	})
+	require.Error(t, err)
+}
+
+func TestDecodePathMessagePackChecks(t *testing.T) {
+	var testcases = []struct {
+		name  string
+		input string
+		path  string
+		opt   Option
+		want  error
+	}{
+		{
+			name:  "duplicate key",
+			input: "82a16101a16102",
+			path:  "$.none",
+			opt:   Strict(),
+			want:  &StrictError{Path: "$.a", Offset: 4, Message: `duplicate key "a"`},
+		},
+		{
+			name:  "duplicate key in matched value",
+			input: "81a16282a16101a16102",
+			path:  "$.b",
+			opt:   Strict(),
+			want:  &StrictError{Path: "$.b.a", Offset: 7, Message: `duplicate key "a"`},
+		},
+		{
+			name:  "invalid UTF-8",
+			input: "81a16191a2ff21",
+			path:  "$.a",
+			opt:   Strict(),
+			want:  &StrictError{Path: "$.a[0]", Offset: 5, Message: "invalid UTF-8 in string"},
+		},
+		{
+			name:  "depth",
+			input: "81a161" + strings.Repeat("91", 10) + "01",
+			path:  "$.none",
+			opt:   WithLimits(Limits{MaxDepth: 10}),
+			want:  &LimitError{Limit: "MaxDepth", Max: 10, Path: "$.a[0][0][0][0][0][0][0][0][0]", Offset: 12},
+		},
+		{
+			name:  "depth in matched value",
+			input: "81a161" + strings.Repeat("91", 10) + "01",
+			path:  "$.a",
+			opt:   WithLimits(Limits{MaxDepth: 10}),
+			want:  &LimitError{Limit: "MaxDepth", Max: 10, Path: "$.a[0][0][0][0][0][0][0][0][0]", Offset: 12},
+		},
+		{
+			name:  "members",
+			input: "83a16101a16202a16303",
+			path:  "$.none",
+			opt:   WithLimits(Limits{MaxMembers: 2}),
+			want:  &LimitError{Limit: "MaxMembers", Max: 2, Path: "$.c", Offset: 7},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			dec := NewStreamDecoder(bytes.NewReader(decodeHex(t, tc.input)), MessagePack(false), tc.opt)
+			err := dec.DecodePath(tc.path, func(key []byte, message json.RawMessage) error {
+				return nil
+			})
+			require.Equal(t, tc.want, err)
+		})
+	}
+}
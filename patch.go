@@ -0,0 +1,332 @@
+package jspath
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// A PatchOperation is a JSON Patch (RFC 6902) operation.
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+var (
+	// ErrPatchInvalid is returned for malformed operations.
+	ErrPatchInvalid = errors.New("jspath: invalid patch operation")
+	// ErrPatchTestFailed is returned when a test operation does not hold.
+	ErrPatchTestFailed = errors.New("jspath: patch test failed")
+	// ErrPatchNotFound is returned when the location of an operation does not exist.
+	ErrPatchNotFound = errors.New("jspath: patch location not found")
+	// ErrPatchOrder is returned when an operation targets a location
+	// that comes before the one of the previous operation.
+	ErrPatchOrder = errors.New("jspath: patch operation out of document order")
+)
+
+// A PatchError describes the operation a patch failed on.
+type PatchError struct {
+	Index int
+	Op    PatchOperation
+	Err   error
+}
+
+func (e *PatchError) Error() string {
+	return fmt.Sprintf("jspath: patch operation %d (%s %s): %v", e.Index, e.Op.Op, e.Op.Path, e.Err)
+}
+
+func (e *PatchError) Unwrap() error {
+	return e.Err
+}
+
+// ApplyPatch streams the document read from r to w applying the JSON Patch operations.
+//
+// The document is never held in memory, so operations are resolved in a single pass:
+// each operation must apply at or after the location of the previous one in
+// document order, and the from location of copy and move must come before their path.
+// Array indices are those of the patched document, as if operations were applied
+// one after the other. Objects members are added at the end of their object.
+// The opts configure the underlying StreamRewriter.
+func ApplyPatch(r io.Reader, w io.Writer, patch []PatchOperation, opts ...Option) error {
+	p := &patcher{ops: make([]patchOp, len(patch)), deltas: map[string]int{}}
+	for i := range patch {
+		if err := p.ops[i].parse(patch[i]); err != nil {
+			return &PatchError{Index: i, Op: patch[i], Err: err}
+		}
+	}
+	rw := NewStreamRewriter(r, w, opts...)
+	p.path = &rw.dec.path
+	if err := rw.run(p); err != nil {
+		return err
+	}
+	if p.next < len(p.ops) {
+		return p.error(p.next, ErrPatchNotFound)
+	}
+	return nil
+}
+
+type patchOp struct {
+	PatchOperation
+	path []string
+	from []string
+
+	// captured holds the value at from once copy and move reached it.
+	captured    json.RawMessage
+	hasCaptured bool
+}
+
+func (op *patchOp) parse(operation PatchOperation) error {
+	op.PatchOperation = operation
+	var err error
+	if op.path, err = parsePointer(operation.Path); err != nil {
+		return err
+	}
+	switch op.Op {
+	case "add", "replace", "test":
+		if len(op.Value) == 0 {
+			return ErrPatchInvalid
+		}
+	case "remove":
+	case "copy", "move":
+		if op.from, err = parsePointer(operation.From); err != nil {
+			return err
+		}
+		if op.Op == "move" && len(op.path) > len(op.from) && reflect.DeepEqual(op.path[:len(op.from)], op.from) {
+			// a value cannot be moved into itself
+			return ErrPatchInvalid
+		}
+	default:
+		return ErrPatchInvalid
+	}
+	return nil
+}
+
+// target returns the location the operation waits for.
+func (op *patchOp) target() []string {
+	if op.from != nil && !op.hasCaptured {
+		return op.from
+	}
+	return op.path
+}
+
+// inserts reports whether the operation adds a value at its target.
+func (op *patchOp) inserts() bool {
+	return op.Op == "add" || op.hasCaptured
+}
+
+func (op *patchOp) value() json.RawMessage {
+	if op.hasCaptured {
+		return op.captured
+	}
+	return op.Value
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) in its unescaped tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if pointer[0] != '/' {
+		return nil, ErrPatchInvalid
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(tokens[i], "~1", "/", -1), "~0", "~", -1)
+	}
+	return tokens, nil
+}
+
+// patcher is the rewriteHandler applying a JSON Patch.
+type patcher struct {
+	ops []patchOp
+	// next is the index of the operation being applied.
+	next int
+	// deltas holds, by array path, the elements inserted minus the elements
+	// removed so far, it maps the input indices to the patched ones.
+	deltas map[string]int
+	// path is the path of the rewriter, the pointers are compared with its segments.
+	path *pathBuilder
+}
+
+func (p *patcher) error(i int, err error) error {
+	return &PatchError{Index: i, Op: p.ops[i].PatchOperation, Err: err}
+}
+
+func (p *patcher) rewrite(path []byte, c byte) (RewriteFunc, error) {
+	if p.next >= len(p.ops) {
+		return nil, nil
+	}
+	op := &p.ops[p.next]
+	if p.matches(path, op.target()) {
+		return p.apply, nil
+	}
+	if op.from != nil && !op.hasCaptured && p.matches(path, op.path) {
+		// the path of copy and move is passed before their from location
+		return nil, p.error(p.next, ErrPatchOrder)
+	}
+	for i := p.next + 1; i < len(p.ops); i++ {
+		if p.matches(path, p.ops[i].target()) {
+			return nil, p.error(i, ErrPatchOrder)
+		}
+	}
+	return nil, nil
+}
+
+// apply applies the operations at path to its value.
+func (p *patcher) apply(path []byte, message json.RawMessage) (json.RawMessage, error) {
+	value := message
+	deleted := false
+	// elements inserted before an array element, when they follow other operations on it
+	var inserted []byte
+	element := p.element(path)
+	for ; p.next < len(p.ops); p.next++ {
+		op := &p.ops[p.next]
+		if !p.matches(path, op.target()) {
+			break
+		}
+		if op.inserts() {
+			if element {
+				inserted = append(append(inserted, op.value()...), ',')
+				p.deltas[string(p.arrayPath(path))]++
+				continue
+			}
+			value, deleted = op.value(), false
+			continue
+		}
+		if deleted {
+			return nil, p.error(p.next, ErrPatchNotFound)
+		}
+		switch op.Op {
+		case "test":
+			if !jsonEqual(value, op.Value) {
+				return nil, p.error(p.next, ErrPatchTestFailed)
+			}
+		case "replace":
+			value = op.Value
+		case "remove":
+			deleted = true
+			p.removed(path)
+		default:
+			// from location of copy and move, the operation then waits for its path
+			op.captured = append([]byte(nil), value...)
+			op.hasCaptured = true
+			if op.Op == "move" {
+				deleted = true
+				p.removed(path)
+			}
+			p.next--
+		}
+	}
+	switch {
+	case deleted && inserted == nil:
+		return nil, Delete
+	case deleted:
+		return inserted[:len(inserted)-1], nil
+	case inserted != nil:
+		return append(inserted, value...), nil
+	}
+	return value, nil
+}
+
+func (p *patcher) insertBefore(path []byte) ([]json.RawMessage, error) {
+	var elements []json.RawMessage
+	for ; p.next < len(p.ops); p.next++ {
+		op := &p.ops[p.next]
+		if !op.inserts() || !p.matches(path, op.path) {
+			break
+		}
+		elements = append(elements, op.value())
+		p.deltas[string(p.arrayPath(path))]++
+	}
+	return elements, nil
+}
+
+func (p *patcher) insertEnd(path []byte, delim byte, members int) ([]rawMember, error) {
+	var inserted []rawMember
+	for ; p.next < len(p.ops); p.next++ {
+		op := &p.ops[p.next]
+		target := op.target()
+		if len(target) == 0 || !p.matches(path, target[:len(target)-1]) {
+			break
+		}
+		last := target[len(target)-1]
+		if delim == ']' && last != "-" {
+			length := members + p.deltas[string(path)]
+			index, err := strconv.Atoi(last)
+			switch {
+			case err != nil || last != strconv.Itoa(index) || index > length:
+				return nil, p.error(p.next, ErrPatchNotFound)
+			case index < length:
+				// the element was passed
+				return nil, p.error(p.next, ErrPatchOrder)
+			}
+		}
+		if !op.inserts() {
+			return nil, p.error(p.next, ErrPatchNotFound)
+		}
+		var key []byte
+		if delim == '}' {
+			key, _ = json.Marshal(last)
+		} else {
+			p.deltas[string(path)]++
+		}
+		inserted = append(inserted, rawMember{key: key, value: op.value()})
+	}
+	if delim == ']' {
+		delete(p.deltas, string(path))
+	}
+	return inserted, nil
+}
+
+// removed records the removal of the value at path.
+func (p *patcher) removed(path []byte) {
+	if p.element(path) {
+		p.deltas[string(p.arrayPath(path))]--
+	}
+}
+
+// element reports whether path, the current path, is the one of an array element.
+func (p *patcher) element(path []byte) bool {
+	return len(path) == len(p.path.path) && p.path.indices.Peek() >= 0
+}
+
+// matches reports whether path designates the location of the pointer tokens,
+// comparing them with its segments.
+func (p *patcher) matches(path []byte, tokens []string) bool {
+	location := p.path.locationAt(len(path))
+	if len(location) != len(tokens) {
+		return false
+	}
+	end := p.path.stackSegmentsSizes[0]
+	for i, segment := range location {
+		if segment.Name == nil {
+			// the indices are shifted by the operations on the array at path[:end]
+			if strconv.Itoa(segment.Index+p.deltas[string(path[:end])]) != tokens[i] {
+				return false
+			}
+		} else if BytesToString(segment.Name) != tokens[i] {
+			return false
+		}
+		end += p.path.stackSegmentsSizes[i+1]
+	}
+	return true
+}
+
+// arrayPath returns the path of the array holding the element at path, the current path.
+func (p *patcher) arrayPath(path []byte) []byte {
+	return path[:len(path)-p.path.stackSegmentsSizes.Peek()]
+}
+
+func jsonEqual(a, b json.RawMessage) bool {
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
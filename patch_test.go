@@ -0,0 +1,271 @@
+package jspath
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyPatch(t *testing.T) {
+	var testcases = []struct {
+		name  string
+		input string
+		patch string
+		want  string
+	}{
+		{
+			name:  "add member",
+			input: `{"foo": "bar"}`,
+			patch: `[{"op": "add", "path": "/baz", "value": "qux"}]`,
+			want:  `{"foo": "bar","baz":"qux"}`,
+		},
+		{
+			name:  "add existing member",
+			input: `{"foo": "bar", "baz": 1}`,
+			patch: `[{"op": "add", "path": "/foo", "value": "qux"}]`,
+			want:  `{"foo": "qux", "baz": 1}`,
+		},
+		{
+			name:  "add element",
+			input: `{"foo": ["bar", "baz"]}`,
+			patch: `[{"op": "add", "path": "/foo/1", "value": "qux"}]`,
+			want:  `{"foo": ["bar","qux", "baz"]}`,
+		},
+		{
+			name:  "add elements at the end",
+			input: `{"foo": ["bar"]}`,
+			patch: `[{"op": "add", "path": "/foo/1", "value": 1}, {"op": "add", "path": "/foo/-", "value": 2}]`,
+			want:  `{"foo": ["bar",1,2]}`,
+		},
+		{
+			name:  "add elements after the end",
+			input: `{"a": [1, 2]}`,
+			patch: `[{"op": "add", "path": "/a/2", "value": 3}, {"op": "add", "path": "/a/3", "value": 4}, {"op": "add", "path": "/a/4", "value": 5}]`,
+			want:  `{"a": [1, 2,3,4,5]}`,
+		},
+		{
+			name:  "add nested member",
+			input: `{"foo": "bar"}`,
+			patch: `[{"op": "add", "path": "/child", "value": {"grandchild": {}}}]`,
+			want:  `{"foo": "bar","child":{"grandchild": {}}}`,
+		},
+		{
+			name:  "remove member",
+			input: `{"baz": "qux", "foo": "bar"}`,
+			patch: `[{"op": "remove", "path": "/baz"}]`,
+			want:  `{"foo": "bar"}`,
+		},
+		{
+			name:  "remove elements",
+			input: `{"foo": ["bar", "qux", "baz"]}`,
+			patch: `[{"op": "remove", "path": "/foo/1"}, {"op": "remove", "path": "/foo/1"}]`,
+			want:  `{"foo": ["bar"]}`,
+		},
+		{
+			name:  "replace",
+			input: `{"baz": "qux", "foo": "bar"}`,
+			patch: `[{"op": "replace", "path": "/baz", "value": "boo"}]`,
+			want:  `{"baz": "boo", "foo": "bar"}`,
+		},
+		{
+			name:  "move member",
+			input: `{"foo": {"bar": "baz", "waldo": "fred"}, "qux": {"corge": "grault"}}`,
+			patch: `[{"op": "move", "from": "/foo/waldo", "path": "/qux/thud"}]`,
+			want:  `{"foo": {"bar": "baz"}, "qux": {"corge": "grault","thud":"fred"}}`,
+		},
+		{
+			name:  "move element",
+			input: `{"foo": ["all", "grass", "cows", "eat"]}`,
+			patch: `[{"op": "move", "from": "/foo/1", "path": "/foo/3"}]`,
+			want:  `{"foo": ["all", "cows", "eat","grass"]}`,
+		},
+		{
+			name:  "copy",
+			input: `{"a": [1, {"b": 2}], "c": {}}`,
+			patch: `[{"op": "copy", "from": "/a/1", "path": "/c/d"}]`,
+			want:  `{"a": [1, {"b": 2}], "c": {"d":{"b": 2}}}`,
+		},
+		{
+			name:  "test then add",
+			input: `{"foo": ["bar", "baz"]}`,
+			patch: `[{"op": "test", "path": "/foo/1", "value": "baz"}, {"op": "add", "path": "/foo/1", "value": "qux"}, {"op": "test", "path": "/foo/2", "value": "baz"}]`,
+			want:  `{"foo": ["bar", "qux","baz"]}`,
+		},
+		{
+			name:  "escaped pointer",
+			input: `{"a/b": 1, "m~n": 2}`,
+			patch: `[{"op": "replace", "path": "/a~1b", "value": 3}, {"op": "remove", "path": "/m~0n"}]`,
+			want:  `{"a/b": 3}`,
+		},
+		{
+			name:  "root",
+			input: `{"a": 1}`,
+			patch: `[{"op": "replace", "path": "", "value": [1]}]`,
+			want:  `[1]`,
+		},
+		{
+			name:  "root array",
+			input: `[1, 2]`,
+			patch: `[{"op": "remove", "path": "/0"}, {"op": "add", "path": "/-", "value": 3}]`,
+			want:  `[2,3]`,
+		},
+		{
+			name:  "large document",
+			input: testdata,
+			patch: `[{"op": "remove", "path": "/store/book/0"}, {"op": "replace", "path": "/store/book/2/price", "value": 1}, {"op": "add", "path": "/store/bicycle/gears", "value": 21}]`,
+			want: `{"store": {"book": [{"category": "fiction","author": "Evelyn Waugh","title": "Sword of Honour","price": 12.99},
+				{"category": "fiction","author": "Herman Melville","title": "Moby Dick","isbn": "0-553-21311-3","price": 8.99},
+				{"category": "fiction","author": "J. R. R. Tolkien","title": "The Lord of the Rings","isbn": "0-395-19395-8","price": 1}],
+				"bicycle": {"color": "red","price": 19.95,"gears":21}},"expensive": 10}`,
+		},
+		{
+			name:  "keys with dots and brackets",
+			input: `{"a.b": 1, "a": {"b": 2}, "c[0]": [3], "c": [4]}`,
+			patch: `[{"op": "replace", "path": "/a.b", "value": 5}, {"op": "replace", "path": "/a/b", "value": 6}, {"op": "add", "path": "/c[0]/0", "value": 7}, {"op": "remove", "path": "/c/0"}]`,
+			want:  `{"a.b": 5, "a": {"b": 6}, "c[0]": [7,3], "c": []}`,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var patch []PatchOperation
+			require.NoError(t, json.Unmarshal([]byte(tc.patch), &patch))
+			var out bytes.Buffer
+			require.NoError(t, ApplyPatch(strings.NewReader(tc.input), &out, patch))
+			require.JSONEq(t, tc.want, out.String())
+			if !strings.Contains(tc.want, "\n") {
+				require.Equal(t, tc.want, out.String())
+			}
+		})
+	}
+}
+
+func TestApplyPatchErrors(t *testing.T) {
+	var testcases = []struct {
+		name  string
+		input string
+		patch string
+		want  error
+		index int
+	}{
+		{
+			name:  "unknown operation",
+			patch: `[{"op": "merge", "path": "/a"}]`,
+			want:  ErrPatchInvalid,
+		},
+		{
+			name:  "missing value",
+			patch: `[{"op": "add", "path": "/a"}]`,
+			want:  ErrPatchInvalid,
+		},
+		{
+			name:  "move into itself",
+			patch: `[{"op": "move", "from": "/a", "path": "/a/b"}]`,
+			want:  ErrPatchInvalid,
+		},
+		{
+			name:  "test failed",
+			input: `{"a": {"b": 1}}`,
+			patch: `[{"op": "test", "path": "/a", "value": {"b": 2}}]`,
+			want:  ErrPatchTestFailed,
+		},
+		{
+			name:  "missing member",
+			input: `{"a": 1}`,
+			patch: `[{"op": "remove", "path": "/b"}]`,
+			want:  ErrPatchNotFound,
+		},
+		{
+			name:  "missing parent",
+			input: `{"a": 1}`,
+			patch: `[{"op": "add", "path": "/b/c", "value": 1}]`,
+			want:  ErrPatchNotFound,
+		},
+		{
+			name:  "index out of range",
+			input: `{"a": [1]}`,
+			patch: `[{"op": "add", "path": "/a/2", "value": 1}]`,
+			want:  ErrPatchNotFound,
+		},
+		{
+			name:  "dotted pointer",
+			input: `{"a.b": 1}`,
+			patch: `[{"op": "replace", "path": "/a/b", "value": 2}]`,
+			want:  ErrPatchNotFound,
+		},
+		{
+			name:  "bracket key is not an index",
+			input: `{"a[0]": 1, "a": {}}`,
+			patch: `[{"op": "remove", "path": "/a/0"}]`,
+			want:  ErrPatchNotFound,
+		},
+		{
+			name:  "reverse document order",
+			input: `{"a": 1, "b": 2}`,
+			patch: `[{"op": "replace", "path": "/b", "value": 3}, {"op": "replace", "path": "/a", "value": 3}]`,
+			want:  ErrPatchOrder,
+			index: 1,
+		},
+		{
+			name:  "copy to a passed member",
+			input: `{"a": 1, "b": 2}`,
+			patch: `[{"op": "copy", "from": "/b", "path": "/a"}]`,
+			want:  ErrPatchOrder,
+		},
+		{
+			name:  "move to a passed member",
+			input: `{"a": 1, "b": 2}`,
+			patch: `[{"op": "move", "from": "/b", "path": "/a"}]`,
+			want:  ErrPatchOrder,
+		},
+		{
+			name:  "copy to a parent",
+			input: `{"a": {"b": 1}}`,
+			patch: `[{"op": "copy", "from": "/a/b", "path": "/a"}]`,
+			want:  ErrPatchOrder,
+		},
+		{
+			name:  "move to a passed element",
+			input: `[1, 2, 3]`,
+			patch: `[{"op": "move", "from": "/2", "path": "/1"}]`,
+			want:  ErrPatchOrder,
+		},
+		{
+			name:  "add at an inserted index",
+			input: `{"a": [1, 2]}`,
+			patch: `[{"op": "add", "path": "/a/1", "value": 3}, {"op": "add", "path": "/a/1", "value": 4}]`,
+			want:  ErrPatchOrder,
+			index: 1,
+		},
+		{
+			name:  "add at an index inserted at the end",
+			input: `{"a": [1, 2]}`,
+			patch: `[{"op": "add", "path": "/a/2", "value": 3}, {"op": "add", "path": "/a/2", "value": 4}]`,
+			want:  ErrPatchOrder,
+			index: 1,
+		},
+		{
+			name:  "copy from a later location",
+			input: `{"a": {}, "b": 2}`,
+			patch: `[{"op": "copy", "from": "/b", "path": "/a/b"}]`,
+			want:  ErrPatchNotFound,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var patch []PatchOperation
+			require.NoError(t, json.Unmarshal([]byte(tc.patch), &patch))
+			var out bytes.Buffer
+			err := ApplyPatch(strings.NewReader(tc.input), &out, patch)
+			var perr *PatchError
+			require.True(t, errors.As(err, &perr), "%v", err)
+			require.Equal(t, tc.want, perr.Err)
+			require.Equal(t, tc.index, perr.Index)
+		})
+	}
+}
@@ -1,9 +1,14 @@
 package jspath
 
 import (
+	"bytes"
 	"reflect"
 	"runtime"
 	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
 	"unsafe"
 )
 
@@ -19,6 +24,9 @@ func BytesToString(bytes []byte) (s string) {
 type pathBuilder struct {
 	path               []byte
 	stackSegmentsSizes sizeStacks
+	// indices holds the array index of each segment, -1 for the members and the root.
+	indices  sizeStacks
+	location Location
 
 	indexBuf [4 * 16]byte
 }
@@ -27,6 +35,7 @@ func newPathBuilder() pathBuilder {
 	p := pathBuilder{
 		path:               make([]byte, 0, 256),
 		stackSegmentsSizes: make([]int, 0, 256),
+		indices:            make([]int, 0, 256),
 	}
 	p.Reset()
 	return p
@@ -35,17 +44,21 @@ func newPathBuilder() pathBuilder {
 func (pb *pathBuilder) Reset() {
 	pb.path = pb.path[0:0]
 	pb.stackSegmentsSizes = pb.stackSegmentsSizes[0:0]
+	pb.indices = pb.indices[0:0]
 	pb.extend(1)
 	pb.path[len(pb.path)-1] = '$'
 	pb.stackSegmentsSizes.Push(1)
+	pb.indices.Push(-1)
 }
 
 func (pb *pathBuilder) StartObject() {
 	pb.stackSegmentsSizes.Push(0)
+	pb.indices.Push(-1)
 }
 
 func (pb *pathBuilder) EndObject() {
 	pb.shrink(pb.stackSegmentsSizes.Pop())
+	pb.indices.Pop()
 }
 
 func (pb *pathBuilder) StartArray() {
@@ -58,6 +71,7 @@ func (pb *pathBuilder) StartArray() {
 		pb.path[pathLen-2] = '0'
 		pb.path[pathLen-1] = ']'
 		pb.stackSegmentsSizes.Push(4)
+		pb.indices.Push(0)
 		return
 	}
 	pb.extend(3)
@@ -66,26 +80,22 @@ func (pb *pathBuilder) StartArray() {
 	pb.path[pathLen-2] = '0'
 	pb.path[pathLen-1] = ']'
 	pb.stackSegmentsSizes.Push(3)
+	pb.indices.Push(0)
 }
 
 func (pb *pathBuilder) EndArray() {
 	pb.shrink(pb.stackSegmentsSizes.Pop())
+	pb.indices.Pop()
 }
 
 func (pb *pathBuilder) IncrementArrayIndex() {
 	size := pb.stackSegmentsSizes.Pop()
-	array := pb.path[len(pb.path)-size:]
 	start := 1
-	if array[0] == '.' {
+	if pb.path[len(pb.path)-size] == '.' {
 		start = 2
 	}
-	index := array[start : len(array)-1]
-
-	i, err := strconv.Atoi(BytesToString(index))
-	if err != nil {
-		panic(err)
-	}
-	i++
+	i := pb.indices.Pop() + 1
+	pb.indices.Push(i)
 	incremented := strconv.AppendInt(pb.indexBuf[:0], int64(i), 10)
 	newSize := len(incremented) + 2
 	if start > 1 {
@@ -107,6 +117,107 @@ func (pb *pathBuilder) SetObjectKey(key []byte) {
 	pb.stackSegmentsSizes.Push(dotPlusKeySize)
 }
 
+// setObjectKey sets the current object key from its quoted JSON form. Paths hold
+// the unescaped keys verbatim, "a\"b" is rendered as $.a"b.
+func (dec *StreamDecoder) setObjectKey(quoted []byte) {
+	key := quoted[1 : len(quoted)-1]
+	if bytes.IndexByte(key, '\\') >= 0 {
+		dec.key = appendUnescaped(dec.key[0:0], key)
+		key = dec.key
+	}
+	dec.path.SetObjectKey(key)
+}
+
+// appendUnescaped appends the content of a valid JSON string, without its quotes,
+// with its escape sequences decoded. Lone surrogates are replaced by U+FFFD.
+func appendUnescaped(dst []byte, s []byte) []byte {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i+1 == len(s) {
+			dst = append(dst, c)
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'b':
+			dst = append(dst, '\b')
+		case 'f':
+			dst = append(dst, '\f')
+		case 'n':
+			dst = append(dst, '\n')
+		case 'r':
+			dst = append(dst, '\r')
+		case 't':
+			dst = append(dst, '\t')
+		case 'u':
+			r := hexRune(s[i+1:])
+			i += 4
+			if utf16.IsSurrogate(r) {
+				r2 := unicode.ReplacementChar
+				if i+6 < len(s) && s[i+1] == '\\' && s[i+2] == 'u' {
+					r2 = hexRune(s[i+3:])
+				}
+				if r = utf16.DecodeRune(r, r2); r != unicode.ReplacementChar {
+					i += 6
+				}
+			}
+			dst = utf8.AppendRune(dst, r)
+		default:
+			dst = append(dst, s[i])
+		}
+	}
+	return dst
+}
+
+// hexRune decodes the 4 hexadecimal digits s starts with, -1 when they are invalid.
+func hexRune(s []byte) rune {
+	if len(s) < 4 {
+		return -1
+	}
+	var r rune
+	for _, c := range s[:4] {
+		switch {
+		case '0' <= c && c <= '9':
+			c -= '0'
+		case 'a' <= c && c <= 'f':
+			c -= 'a' - 10
+		case 'A' <= c && c <= 'F':
+			c -= 'A' - 10
+		default:
+			return -1
+		}
+		r = r*16 + rune(c)
+	}
+	return r
+}
+
+// restore replaces the current path with path, split in segments of the given
+// sizes. indices holds the array index of each segment, -1 for the root and the members.
+func (pb *pathBuilder) restore(path string, segments []int, indices []int) error {
+	total := 0
+	for _, size := range segments {
+		if size < 0 {
+			return errInvalidCheckpoint
+		}
+		total += size
+	}
+	if total != len(path) || len(path) == 0 || path[0] != '$' || indices[0] != -1 {
+		return errInvalidCheckpoint
+	}
+	end := segments[0]
+	for i, size := range segments[1:] {
+		segment := path[end : end+size]
+		end += size
+		if index := indices[i+1]; index >= 0 && strings.TrimPrefix(segment, ".") != "["+strconv.Itoa(index)+"]" || index < -1 {
+			return errInvalidCheckpoint
+		}
+	}
+	pb.path = append(pb.path[0:0], path...)
+	pb.stackSegmentsSizes = append(pb.stackSegmentsSizes[0:0], segments...)
+	pb.indices = append(pb.indices[0:0], indices...)
+	return nil
+}
+
 func (pb *pathBuilder) Path() string {
 	return *(*string)(unsafe.Pointer(&pb.path))
 }
@@ -115,10 +226,14 @@ func (pb *pathBuilder) PathBytes() []byte {
 	return pb.path
 }
 
+// extend grows the path by n bytes. The slices of the path taken before keep
+// viewing its previous content when it is reallocated.
 func (pb *pathBuilder) extend(n int) {
 	newSize := len(pb.path) + n
-	if newSize >= cap(pb.path) {
-		panic("please increase math path length")
+	if newSize > cap(pb.path) {
+		path := make([]byte, len(pb.path), 2*cap(pb.path)+n)
+		copy(path, pb.path)
+		pb.path = path
 	}
 	pb.path = pb.path[:newSize]
 }
@@ -151,6 +266,5 @@ func (stack *sizeStacks) Pop() int {
 }
 
 func (stack *sizeStacks) Push(v int) {
-	*stack = (*stack)[:len(*stack)+1]
-	(*stack)[len(*stack)-1] = v
+	*stack = append(*stack, v)
 }
@@ -1,9 +1,12 @@
 package jspath
 
 import (
-	"github.com/stretchr/testify/require"
+	"encoding/json"
 	"log"
+	"strings"
 	"testing"
+
+	"github.com/stretchr/testify/require"
 )
 
 func init() {
@@ -145,3 +148,55 @@ func BenchmarkNewPathBuilder2(b *testing.B) {
 
 	}
 }
+
+func TestAppendUnescaped(t *testing.T) {
+	var testcases = []struct {
+		input string
+		want  string
+	}{
+		{input: `price`, want: "price"},
+		{input: `\u0070rice`, want: "price"},
+		{input: `a\"b`, want: `a"b`},
+		{input: `\\\/\b\f\n\r\t`, want: "\\/\b\f\n\r\t"},
+		{input: `é😀`, want: "é😀"},
+		{input: `\ud83dx`, want: "�x"},
+		{input: `\ude00\ud83d`, want: "��"},
+	}
+	for _, tc := range testcases {
+		require.Equal(t, tc.want, string(appendUnescaped(nil, []byte(tc.input))), tc.input)
+	}
+}
+
+func TestDecodePathEscapedKeys(t *testing.T) {
+	input := `{"\u0070rice": 1, "a\"b": {"c": 2}, "plain": 3, "x\ny": [4]}`
+	var got []string
+	dec := NewStreamDecoder(strings.NewReader(input))
+	require.NoError(t, dec.DecodePath("$.*", func(key []byte, message json.RawMessage) error {
+		got = append(got, string(key)+" "+string(message))
+		return nil
+	}))
+	require.Equal(t, []string{"$.price 1", `$.a"b {"c": 2}`, "$.plain 3", "$.x\ny [4]"}, got)
+
+	got = got[0:0]
+	dec = NewStreamDecoder(strings.NewReader(input))
+	require.NoError(t, dec.DecodePath(`$.a"b.c`, func(key []byte, message json.RawMessage) error {
+		got = append(got, string(key)+" "+string(message))
+		return nil
+	}))
+	require.NoError(t, NewStreamDecoder(strings.NewReader(input)).DecodePath("$.price", func(key []byte, message json.RawMessage) error {
+		got = append(got, string(key)+" "+string(message))
+		return nil
+	}))
+	require.Equal(t, []string{`$.a"b.c 2`, "$.price 1"}, got)
+}
+
+func BenchmarkSetObjectKey(b *testing.B) {
+	dec := NewStreamDecoder(strings.NewReader(""))
+	dec.path.StartObject()
+	plain, escaped := []byte(`"price"`), []byte(`"\u0070rice"`)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		dec.setObjectKey(plain)
+		dec.setObjectKey(escaped)
+	}
+}
@@ -0,0 +1,112 @@
+package jspath
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+)
+
+// A Projector writes a pruned copy of a JSON stream that only contains the
+// values at specified json paths, nested in the structure they have in the input.
+//
+// Containers are only written once a value inside them matches, so
+// arrays are compacted: their elements keep their order but not their index.
+// Top level documents are separated by a newline.
+type Projector struct {
+	dec *StreamDecoder
+	w   *bufio.Writer
+
+	frames []projectFrame
+	// documents is the amount of top level values written.
+	documents int
+	key       []byte
+}
+
+type projectFrame struct {
+	delim   byte
+	pathLen int
+	written bool
+	members int
+}
+
+// NewProjector returns a new Projector that reads from r and writes to w.
+func NewProjector(r io.Reader, w io.Writer, opts ...Option) *Projector {
+	p := &Projector{dec: NewStreamDecoder(r, opts...), w: bufio.NewWriter(w)}
+	p.dec.observer = p
+	return p
+}
+
+// Project writes the projection of the whole input on paths.
+func (p *Projector) Project(paths ...string) error {
+	decoders := make([]UnmarshalerStream, 0, len(paths))
+	for _, path := range paths {
+		decoders = append(decoders, NewRawStreamUnmarshaler(path, p.project))
+	}
+	err := p.dec.Decode(decoders...)
+	if ferr := p.w.Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+func (p *Projector) openContainer(delim byte, path []byte) error {
+	p.frames = append(p.frames, projectFrame{delim: delim, pathLen: len(path)})
+	return nil
+}
+
+func (p *Projector) closeContainer(delim byte) error {
+	frame := p.frames[len(p.frames)-1]
+	p.frames = p.frames[:len(p.frames)-1]
+	if !frame.written {
+		return nil
+	}
+	p.w.WriteByte(delim)
+	if len(p.frames) == 0 {
+		p.documents++
+	}
+	return nil
+}
+
+func (p *Projector) literal(path []byte, value []byte) error {
+	return nil
+}
+
+// project writes the containers around the matched value that were
+// not written yet, then the value itself.
+func (p *Projector) project(key []byte, message json.RawMessage) error {
+	for i := range p.frames {
+		if p.frames[i].written {
+			continue
+		}
+		p.member(i, key[:p.frames[i].pathLen])
+		p.w.WriteByte(p.frames[i].delim)
+		p.frames[i].written = true
+	}
+	p.member(len(p.frames), key)
+	if len(p.frames) == 0 {
+		p.documents++
+	}
+	_, err := p.w.Write(message)
+	return err
+}
+
+// member writes the separator and the key of the member at path in the frame at depth.
+func (p *Projector) member(depth int, path []byte) {
+	if depth == 0 {
+		if p.documents > 0 {
+			p.w.WriteByte('\n')
+		}
+		return
+	}
+	parent := &p.frames[depth-1]
+	if parent.members > 0 {
+		p.w.WriteByte(',')
+	}
+	parent.members++
+	if parent.delim == '{' {
+		// the path segment of a member is .key, with the key unescaped
+		p.key = appendJSONString(p.key[0:0], path[parent.pathLen+1:])
+		p.w.Write(p.key)
+		p.w.WriteByte(':')
+	}
+}
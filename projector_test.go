@@ -0,0 +1,82 @@
+package jspath
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjector(t *testing.T) {
+	var testcases = []struct {
+		name  string
+		paths []string
+		input string
+		want  string
+	}{
+		{
+			name:  "array members",
+			paths: []string{"$.store.book[*].title", "$.expensive"},
+			input: testdata,
+			want:  `{"store":{"book":[{"title":"Sayings of the Century"},{"title":"Sword of Honour"},{"title":"Moby Dick"},{"title":"The Lord of the Rings"}]},"expensive":10}`,
+		},
+		{
+			name:  "sparse array",
+			paths: []string{"$.store.book[*].isbn"},
+			input: testdata,
+			want:  `{"store":{"book":[{"isbn":"0-553-21311-3"},{"isbn":"0-395-19395-8"}]}}`,
+		},
+		{
+			name:  "subtree",
+			paths: []string{"$.store.bicycle"},
+			input: `{"store": {"book": [], "bicycle": {"color": "red"}}, "expensive": 10}`,
+			want:  `{"store":{"bicycle":{"color": "red"}}}`,
+		},
+		{
+			name:  "escaped keys",
+			paths: []string{`$.a"b.c`, "$.x\\y", "$.\n"},
+			input: `{"a\"b": {"c": 1, "d": 2}, "x\\y": 3, "\n": 4, "\u006e": 5}`,
+			want:  `{"a\"b":{"c":1},"x\\y":3,"\n":4}`,
+		},
+		{
+			name:  "array elements",
+			paths: []string{"$.a[1]", "$.a[2]"},
+			input: `{"a": [1, [2], {"b": 3}], "c": 4}`,
+			want:  `{"a":[[2],{"b": 3}]}`,
+		},
+		{
+			name:  "multiple documents",
+			paths: []string{"$.a"},
+			input: `{"a": 1, "b": 2} {"b": 3} {"a": 4}`,
+			want:  "{\"a\":1}\n{\"a\":4}",
+		},
+		{
+			name:  "root values",
+			paths: []string{"$."},
+			input: `1 {"a": 2}`,
+			want:  "1\n{\"a\": 2}",
+		},
+		{
+			name:  "root array",
+			paths: []string{"$.[*].a"},
+			input: `[{"a": 1, "b": 2}, {"b": 3}]`,
+			want:  `[{"a":1}]`,
+		},
+		{
+			name:  "no match",
+			paths: []string{"$.z"},
+			input: testdata,
+			want:  ``,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := NewProjector(strings.NewReader(tc.input), &out).Project(tc.paths...)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, out.String())
+		})
+	}
+}
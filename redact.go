@@ -0,0 +1,121 @@
+package jspath
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+)
+
+// DefaultMask is the mask used by Redact.
+const DefaultMask = "***"
+
+// A Redactor masks the values found at json paths while streaming a document.
+//
+// Strings are replaced by the mask, or by their hash when Hash is set.
+// The other literals keep their type: numbers become 0, booleans false and null stays null.
+// Objects and arrays are redacted recursively, their keys are kept.
+type Redactor struct {
+	// Mask replaces the matched strings, DefaultMask is used when empty.
+	Mask string
+	// Hash, when set, replaces the matched strings by the hex encoded hash of their content.
+	Hash func() hash.Hash
+	// Drop removes the matched members and elements instead of masking them.
+	Drop bool
+	// Options are handed to the underlying StreamRewriter.
+	Options []Option
+
+	mask []byte
+	buf  []byte
+}
+
+// Redact copies r to w replacing the strings found at paths by DefaultMask.
+func Redact(r io.Reader, w io.Writer, paths ...string) error {
+	return (&Redactor{}).Redact(r, w, paths...)
+}
+
+// Redact copies r to w redacting the values found at paths.
+// Only the matched values are buffered, memory use does not depend on the document size.
+func (rd *Redactor) Redact(r io.Reader, w io.Writer, paths ...string) error {
+	mask := rd.Mask
+	if mask == "" {
+		mask = DefaultMask
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(mask); err != nil {
+		return err
+	}
+	rd.mask = bytes.TrimSpace(buf.Bytes())
+	rules := make([]RewriteRule, 0, len(paths))
+	for _, path := range paths {
+		rules = append(rules, RewriteRule{Path: path, Rewrite: rd.rewrite})
+	}
+	return NewStreamRewriter(r, w, rd.Options...).Rewrite(rules...)
+}
+
+func (rd *Redactor) rewrite(key []byte, message json.RawMessage) (json.RawMessage, error) {
+	if rd.Drop {
+		return nil, Delete
+	}
+	var err error
+	rd.buf, err = rd.redact(rd.buf[0:0], message)
+	return rd.buf, err
+}
+
+// redact appends value to dst with all its literals redacted.
+func (rd *Redactor) redact(dst []byte, value []byte) ([]byte, error) {
+	var scan scanner
+	scan.reset()
+	start := -1
+	var err error
+	for i, c := range value {
+		op := scan.step(&scan, c)
+		if start >= 0 && op != scanContinue {
+			if dst, err = rd.redactLiteral(dst, value[start:i]); err != nil {
+				return nil, err
+			}
+			start = -1
+		}
+		if op == scanError {
+			return nil, scan.err
+		}
+		// object keys are literals too, they are kept as is
+		if op == scanBeginLiteral && (len(scan.parseState) == 0 || scan.parseState[len(scan.parseState)-1] != parseObjectKey) {
+			start = i
+			continue
+		}
+		if start < 0 {
+			dst = append(dst, c)
+		}
+	}
+	if start >= 0 {
+		return rd.redactLiteral(dst, value[start:])
+	}
+	return dst, nil
+}
+
+func (rd *Redactor) redactLiteral(dst []byte, literal []byte) ([]byte, error) {
+	switch literal[0] {
+	case '"':
+		if rd.Hash == nil {
+			return append(dst, rd.mask...), nil
+		}
+		var s string
+		if err := json.Unmarshal(literal, &s); err != nil {
+			return nil, err
+		}
+		h := rd.Hash()
+		h.Write([]byte(s))
+		dst = append(dst, '"')
+		dst = append(dst, hex.EncodeToString(h.Sum(nil))...)
+		return append(dst, '"'), nil
+	case 't', 'f':
+		return append(dst, "false"...), nil
+	case 'n':
+		return append(dst, "null"...), nil
+	}
+	return append(dst, '0'), nil
+}
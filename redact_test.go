@@ -0,0 +1,87 @@
+package jspath
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedact(t *testing.T) {
+	var testcases = []struct {
+		name     string
+		redactor *Redactor
+		paths    []string
+		input    string
+		want     string
+	}{
+		{
+			name:     "default mask",
+			redactor: &Redactor{},
+			paths:    []string{"$.users[*].email"},
+			input:    `{"users": [{"name": "a", "email": "a@a.com"}, {"email": "b\"@b.com"}]}`,
+			want:     `{"users": [{"name": "a", "email": "***"}, {"email": "***"}]}`,
+		},
+		{
+			name:     "custom mask",
+			redactor: &Redactor{Mask: `<"redacted">`},
+			paths:    []string{"$.a"},
+			input:    `{"a": "secret"}`,
+			want:     `{"a": "<\"redacted\">"}`,
+		},
+		{
+			name:     "literals keep their type",
+			redactor: &Redactor{},
+			paths:    []string{"$.*"},
+			input:    `{"n": -1.5e3, "t": true, "f": false, "z": null}`,
+			want:     `{"n": 0, "t": false, "f": false, "z": null}`,
+		},
+		{
+			name:     "containers",
+			redactor: &Redactor{},
+			paths:    []string{"$.user"},
+			input:    `{"user": {"name": "a", "tags": ["x", 1, {"y": "z"}], "age": 3}, "id": 7}`,
+			want:     `{"user": {"name": "***", "tags": ["***", 0, {"y": "***"}], "age": 0}, "id": 7}`,
+		},
+		{
+			name:     "hash",
+			redactor: &Redactor{Hash: sha256.New},
+			paths:    []string{"$.email"},
+			input:    `{"email": "a@a.com"}`,
+			want:     `{"email": "7dcb0cd247aa94896e665c337696a7be18d387a78d6d55b4854b7ff7d5925042"}`,
+		},
+		{
+			name:     "drop",
+			redactor: &Redactor{Drop: true},
+			paths:    []string{"$.users[*].email", "$.token"},
+			input:    `{"token": "t", "users": [{"name": "a", "email": "a@a.com"}]}`,
+			want:     `{"users": [{"name": "a"}]}`,
+		},
+		{
+			name:     "several paths",
+			redactor: &Redactor{},
+			paths:    []string{"$.a", "$.b[1]"},
+			input:    `{"a": "x", "b": ["y", "z"], "c": "w"}`,
+			want:     `{"a": "***", "b": ["y", "***"], "c": "w"}`,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := tc.redactor.Redact(strings.NewReader(tc.input), &out, tc.paths...)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, out.String())
+		})
+	}
+}
+
+func TestRedactDefault(t *testing.T) {
+	var out bytes.Buffer
+	err := Redact(strings.NewReader(testdata), &out, "$.store.book[*].author")
+	require.NoError(t, err)
+	require.Equal(t, 4, strings.Count(out.String(), `"author": "***"`))
+	require.Equal(t, len(testdata)-len("Nigel Rees")-len("Evelyn Waugh")-len("Herman Melville")-len("J. R. R. Tolkien")+4*3, out.Len())
+}
@@ -0,0 +1,461 @@
+package jspath
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// A RewriteFunc is called with the path and the raw value of a matched member or element.
+// It returns the raw JSON that replaces the value, returning message or nil keeps it unchanged.
+// A returned value that is not valid JSON fails the rewrite with a *SyntaxError.
+// The content of the message is only valid until the function return.
+type RewriteFunc func(key []byte, message json.RawMessage) (json.RawMessage, error)
+
+// Delete can be returned by a RewriteFunc to remove the matched member or element.
+var Delete = errors.New("jspath: delete value")
+
+var errRewriteBinary = errors.New("jspath: binary formats cannot be rewritten")
+
+// A RewriteRule associates a json path with the RewriteFunc applied to its matches.
+type RewriteRule struct {
+	Path    string
+	Rewrite RewriteFunc
+}
+
+type rewriteRule struct {
+	RewriteRule
+	matcher func(curPath, jsPath string) bool
+}
+
+// rewriteRules rewrites the values matching the first of its rules.
+type rewriteRules []rewriteRule
+
+func (rules rewriteRules) rewrite(path []byte, c byte) (RewriteFunc, error) {
+	curPath := BytesToString(path)
+	for i := range rules {
+		if rules[i].matcher(curPath, rules[i].Path) {
+			return rules[i].Rewrite, nil
+		}
+	}
+	return nil, nil
+}
+
+func (rules rewriteRules) insertBefore(path []byte) ([]json.RawMessage, error) {
+	return nil, nil
+}
+
+func (rules rewriteRules) insertEnd(path []byte, delim byte, members int) ([]rawMember, error) {
+	return nil, nil
+}
+
+// A rewriteHandler decides what a StreamRewriter does with the values it copies.
+type rewriteHandler interface {
+	// rewrite returns the function rewriting the value at path, nil copies the value.
+	// c is the first byte of the value.
+	rewrite(path []byte, c byte) (RewriteFunc, error)
+	// insertBefore returns the elements to insert before the array element at path.
+	insertBefore(path []byte) ([]json.RawMessage, error)
+	// insertEnd returns the members to append to the container at path, delim is
+	// its closing delimiter and members the amount of members it had in the input.
+	insertEnd(path []byte, delim byte, members int) ([]rawMember, error)
+}
+
+// A rawMember is an inserted member, key is the quoted key of object members.
+type rawMember struct {
+	key   []byte
+	value json.RawMessage
+}
+
+// A StreamRewriter copies a JSON stream verbatim to a writer,
+// rewriting the values at specified json paths on the way.
+type StreamRewriter struct {
+	dec *StreamDecoder
+	w   *bufio.Writer
+
+	handler rewriteHandler
+	// validate is set when the rewritten values come from the user and must be checked.
+	validate bool
+
+	// pending holds the separator and the spaces read since the last write,
+	// they are only written once we know the next member is kept.
+	pending []byte
+	// kept tells for each open container whether a member was written.
+	kept []bool
+	// members counts the members read in each open container.
+	members []int
+	// lead holds the spaces before the deleted first members of the
+	// current container, they replace the separator of the first kept one.
+	lead    []byte
+	hasLead bool
+}
+
+// NewStreamRewriter returns a new StreamRewriter that reads from r and writes to w.
+// The Strict and WithLimits options check the whole input, rewritten values
+// included. The binary formats are not supported, rewriting then fails.
+func NewStreamRewriter(r io.Reader, w io.Writer, opts ...Option) *StreamRewriter {
+	return &StreamRewriter{dec: NewStreamDecoder(r, opts...), w: bufio.NewWriter(w)}
+}
+
+// RewritePath rewrites the values matching jsPath with fn.
+func (rw *StreamRewriter) RewritePath(jsPath string, fn RewriteFunc) error {
+	return rw.Rewrite(RewriteRule{Path: jsPath, Rewrite: fn})
+}
+
+// Rewrite copies the whole input to the writer, applying the first matching rule
+// to every value. It returns once the input is exhausted.
+func (rw *StreamRewriter) Rewrite(rules ...RewriteRule) error {
+	compiled := make(rewriteRules, 0, len(rules))
+	for i := range rules {
+		matcher, err := rw.dec.compilePath(rules[i].Path)
+		if err != nil {
+			return err
+		}
+		compiled = append(compiled, rewriteRule{RewriteRule: rules[i], matcher: matcher})
+	}
+	rw.validate = true
+	return rw.run(compiled)
+}
+
+// run copies the whole input to the writer as told by handler.
+func (rw *StreamRewriter) run(handler rewriteHandler) error {
+	if rw.dec.format != nil {
+		return errRewriteBinary
+	}
+	rw.handler = handler
+	err := rw.rewrite()
+	if ferr := rw.w.Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+func (rw *StreamRewriter) rewrite() error {
+	dec := rw.dec
+	for {
+		c, err := rw.peek()
+		if err != nil {
+			if err == io.EOF {
+				rw.flush()
+				if len(dec.tokenStack) > 0 {
+					return io.ErrUnexpectedEOF
+				}
+				return nil
+			}
+			return err
+		}
+		switch c {
+		case '[', '{':
+			if !dec.tokenValueAllowed() {
+				return dec.tokenError(c)
+			}
+			if rw.memberStart() {
+				fn, err := rw.match(c)
+				if err != nil {
+					return err
+				}
+				if fn != nil {
+					if err := rw.rewriteValue(fn); err != nil {
+						return err
+					}
+					continue
+				}
+				rw.flushMember()
+			} else {
+				rw.flush()
+			}
+			rw.w.WriteByte(c)
+			dec.scanp++
+			if err := dec.checkOpen(c, dec.path.PathBytes(), dec.offset()-1); err != nil {
+				return err
+			}
+			dec.tokenStack = append(dec.tokenStack, dec.tokenState)
+			rw.kept = append(rw.kept, false)
+			rw.members = append(rw.members, 0)
+			rw.hasLead = false
+			if c == '[' {
+				dec.tokenState = tokenArrayStart
+				dec.path.StartArray()
+			} else {
+				dec.tokenState = tokenObjectStart
+				dec.path.StartObject()
+			}
+
+		case ']', '}':
+			if c == ']' && dec.tokenState != tokenArrayStart && dec.tokenState != tokenArrayComma ||
+				c == '}' && dec.tokenState != tokenObjectStart && dec.tokenState != tokenObjectComma {
+				return dec.tokenError(c)
+			}
+			path := dec.path.PathBytes()
+			members, err := rw.handler.insertEnd(path[:len(path)-dec.path.stackSegmentsSizes.Peek()], c, rw.members[len(rw.members)-1])
+			if err != nil {
+				return err
+			}
+			for _, m := range members {
+				rw.insert(m.key, m.value)
+			}
+			rw.flush()
+			rw.w.WriteByte(c)
+			dec.scanp++
+			dec.tokenState = dec.tokenStack[len(dec.tokenStack)-1]
+			dec.tokenStack = dec.tokenStack[:len(dec.tokenStack)-1]
+			rw.kept = rw.kept[:len(rw.kept)-1]
+			rw.members = rw.members[:len(rw.members)-1]
+			rw.hasLead = false
+			dec.path.EndObject()
+			if c == ']' {
+				dec.checkClose('[')
+			} else {
+				dec.checkClose('{')
+			}
+			dec.tokenValueEnd()
+
+		case ':':
+			if dec.tokenState != tokenObjectColon {
+				return dec.tokenError(c)
+			}
+			rw.flush()
+			rw.w.WriteByte(c)
+			dec.scanp++
+			dec.tokenState = tokenObjectValue
+
+		case ',':
+			switch dec.tokenState {
+			case tokenArrayComma:
+				dec.path.IncrementArrayIndex()
+				dec.tokenState = tokenArrayValue
+			case tokenObjectComma:
+				dec.tokenState = tokenObjectKey
+			default:
+				return dec.tokenError(c)
+			}
+			rw.pending = append(rw.pending, c)
+			dec.scanp++
+
+		case '"':
+			if dec.tokenState == tokenObjectStart || dec.tokenState == tokenObjectKey {
+				if err := rw.member(); err != nil {
+					return err
+				}
+				continue
+			}
+			fallthrough
+
+		default:
+			if !dec.tokenValueAllowed() {
+				return dec.tokenError(c)
+			}
+			if rw.memberStart() {
+				fn, err := rw.match(c)
+				if err != nil {
+					return err
+				}
+				if fn != nil {
+					if err := rw.rewriteValue(fn); err != nil {
+						return err
+					}
+					continue
+				}
+				rw.flushMember()
+			} else {
+				rw.flush()
+			}
+			value, err := rw.readValue()
+			if err != nil {
+				return err
+			}
+			if err := dec.checkValue(dec.path.PathBytes(), value); err != nil {
+				return err
+			}
+			rw.w.Write(value)
+		}
+	}
+}
+
+// member handles an object member starting at its key.
+// The key is held back until we know whether the member is deleted.
+func (rw *StreamRewriter) member() error {
+	dec := rw.dec
+	dec.tokenState = tokenTopValue
+	key, err := rw.readValue()
+	if err != nil {
+		return err
+	}
+	dec.tokenState = tokenObjectColon
+	if err := dec.checkMember(key, dec.offset()-int64(len(key))); err != nil {
+		return err
+	}
+	dec.setObjectKey(key)
+	rw.members[len(rw.members)-1]++
+	rw.pending = append(rw.pending, key...)
+
+	c, err := rw.peek()
+	if err != nil {
+		return err
+	}
+	if c != ':' {
+		return dec.tokenError(c)
+	}
+	rw.pending = append(rw.pending, c)
+	dec.scanp++
+	dec.tokenState = tokenObjectValue
+	if c, err = rw.peek(); err != nil {
+		return err
+	}
+	fn, err := rw.match(c)
+	if err != nil {
+		return err
+	}
+	if fn == nil {
+		rw.flushMember()
+		return nil
+	}
+	return rw.rewriteValue(fn)
+}
+
+// rewriteValue reads the value at the current position and writes its rewrite.
+func (rw *StreamRewriter) rewriteValue(fn RewriteFunc) error {
+	value, err := rw.readValue()
+	if err != nil {
+		return err
+	}
+	if err := rw.dec.checkValue(rw.dec.path.PathBytes(), value); err != nil {
+		return err
+	}
+	out, err := fn(rw.dec.path.PathBytes(), value)
+	if err == Delete {
+		if n := len(rw.kept); n > 0 && !rw.kept[n-1] && !rw.hasLead {
+			rw.lead = append(rw.lead[0:0], rw.pending[:len(rw.pending)-len(skipSeparator(rw.pending))]...)
+			rw.hasLead = true
+		}
+		rw.pending = rw.pending[0:0]
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		out = value
+	} else if rw.validate && !json.Valid(out) {
+		return &SyntaxError{msg: "invalid rewritten value at " + string(rw.dec.path.PathBytes()), Offset: rw.dec.offset() - int64(len(value))}
+	}
+	rw.flushMember()
+	rw.w.Write(out)
+	return nil
+}
+
+// readValue reads a whole value, the returned bytes are only valid until the next read.
+func (rw *StreamRewriter) readValue() ([]byte, error) {
+	dec := rw.dec
+	n, err := dec.readValue()
+	if err != nil {
+		return nil, err
+	}
+	value := dec.buf[dec.scanp : dec.scanp+n]
+	dec.scanp += n
+	dec.tokenValueEnd()
+	return value, nil
+}
+
+// memberStart reports whether the next value starts a new array element or top level value.
+func (rw *StreamRewriter) memberStart() bool {
+	switch rw.dec.tokenState {
+	case tokenTopValue, tokenArrayStart, tokenArrayValue:
+		return true
+	}
+	return false
+}
+
+// match returns the function rewriting the value starting with c at the current path.
+// Array elements may first be preceded by inserted elements.
+func (rw *StreamRewriter) match(c byte) (RewriteFunc, error) {
+	path := rw.dec.path.PathBytes()
+	if rw.dec.tokenState == tokenArrayStart || rw.dec.tokenState == tokenArrayValue {
+		rw.members[len(rw.members)-1]++
+		elements, err := rw.handler.insertBefore(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, element := range elements {
+			rw.insert(nil, element)
+		}
+	}
+	return rw.handler.rewrite(path, c)
+}
+
+// insert writes a new member in the innermost open container.
+func (rw *StreamRewriter) insert(key []byte, value json.RawMessage) {
+	n := len(rw.kept)
+	if rw.kept[n-1] {
+		rw.w.WriteByte(',')
+	} else if rw.hasLead {
+		rw.w.Write(rw.lead)
+		rw.hasLead = false
+	}
+	rw.kept[n-1] = true
+	if key != nil {
+		rw.w.Write(key)
+		rw.w.WriteByte(':')
+	}
+	rw.w.Write(value)
+}
+
+// flush writes the pending bytes as they are.
+func (rw *StreamRewriter) flush() {
+	rw.w.Write(rw.pending)
+	rw.pending = rw.pending[0:0]
+}
+
+// flushMember writes the pending separator of a kept member. When all the
+// previous members were deleted, the spaces that were before the first of
+// them are written instead, when members were inserted before it a comma is added.
+func (rw *StreamRewriter) flushMember() {
+	if n := len(rw.kept); n > 0 {
+		switch sep := len(rw.pending) - len(skipSeparator(rw.pending)); {
+		case !rw.kept[n-1] && rw.hasLead:
+			rw.w.Write(rw.lead)
+			rw.pending = skipSeparator(rw.pending)
+			rw.hasLead = false
+		case rw.kept[n-1] && bytes.IndexByte(rw.pending[:sep], ',') < 0:
+			rw.w.WriteByte(',')
+		}
+		rw.kept[n-1] = true
+	}
+	rw.flush()
+}
+
+// skipSeparator returns b without its leading spaces and comma.
+func skipSeparator(b []byte) []byte {
+	for i, c := range b {
+		if c != ',' && !isSpace(c) {
+			return b[i:]
+		}
+	}
+	return b[len(b):]
+}
+
+// peek returns the next non space byte, the spaces are kept in the pending bytes.
+func (rw *StreamRewriter) peek() (byte, error) {
+	dec := rw.dec
+	var err error
+	for {
+		for i := dec.scanp; i < len(dec.buf); i++ {
+			c := dec.buf[i]
+			if isSpace(c) {
+				continue
+			}
+			rw.pending = append(rw.pending, dec.buf[dec.scanp:i]...)
+			dec.scanp = i
+			return c, nil
+		}
+		rw.pending = append(rw.pending, dec.buf[dec.scanp:]...)
+		dec.scanp = len(dec.buf)
+		// buffer has been scanned, now report any error
+		if err != nil {
+			return 0, err
+		}
+		err = dec.refill()
+	}
+}
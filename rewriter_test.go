@@ -0,0 +1,234 @@
+package jspath
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamRewriter(t *testing.T) {
+	replace := func(with string) RewriteFunc {
+		return func(key []byte, message json.RawMessage) (json.RawMessage, error) {
+			return json.RawMessage(with), nil
+		}
+	}
+	del := func(key []byte, message json.RawMessage) (json.RawMessage, error) {
+		return nil, Delete
+	}
+	keep := func(key []byte, message json.RawMessage) (json.RawMessage, error) {
+		return message, nil
+	}
+
+	var testcases = []struct {
+		name  string
+		rules []RewriteRule
+		input string
+		want  string
+	}{
+		{
+			name:  "verbatim copy",
+			input: testdata,
+			want:  testdata,
+		},
+		{
+			name:  "keep",
+			rules: []RewriteRule{{Path: "$.store.book[*]", Rewrite: keep}},
+			input: testdata,
+			want:  testdata,
+		},
+		{
+			name:  "replace member",
+			rules: []RewriteRule{{Path: "$.users[*].email", Rewrite: replace(`"x"`)}},
+			input: `{"users": [{"name": "a", "email": "a@a.com"}, {"email": "b@b.com", "name": "b"}]}`,
+			want:  `{"users": [{"name": "a", "email": "x"}, {"email": "x", "name": "b"}]}`,
+		},
+		{
+			name:  "replace container",
+			rules: []RewriteRule{{Path: "$.a", Rewrite: replace(`null`)}},
+			input: `{"a": {"b": [1, 2]}, "c": [{}]}`,
+			want:  `{"a": null, "c": [{}]}`,
+		},
+		{
+			name:  "delete middle element",
+			rules: []RewriteRule{{Path: "$.a[1]", Rewrite: del}},
+			input: `{"a": [1, 2, 3]}`,
+			want:  `{"a": [1, 3]}`,
+		},
+		{
+			name:  "delete first element",
+			rules: []RewriteRule{{Path: "$.a[0]", Rewrite: del}},
+			input: "{\"a\": [\n  {\"b\": 1},\n  {\"b\": 2}\n]}",
+			want:  "{\"a\": [\n  {\"b\": 2}\n]}",
+		},
+		{
+			name:  "delete last element",
+			rules: []RewriteRule{{Path: "$.a[2]", Rewrite: del}},
+			input: `{"a": [1, 2, 3]}`,
+			want:  `{"a": [1, 2]}`,
+		},
+		{
+			name:  "delete all elements",
+			rules: []RewriteRule{{Path: "$.a[*]", Rewrite: del}},
+			input: `{"a": [1, 2, 3], "b": 4}`,
+			want:  `{"a": [], "b": 4}`,
+		},
+		{
+			name:  "delete members",
+			rules: []RewriteRule{{Path: "$.users[*].email", Rewrite: del}},
+			input: `{"users": [{"email": "a@a.com", "name": "a"}, {"name": "b", "email": "b@b.com"}, {"email": "c@c.com"}]}`,
+			want:  `{"users": [{"name": "a"}, {"name": "b"}, {}]}`,
+		},
+		{
+			name:  "delete member with comma in key",
+			rules: []RewriteRule{{Path: "$.a", Rewrite: del}},
+			input: `{"a": 1, "b,c": 2}`,
+			want:  `{"b,c": 2}`,
+		},
+		{
+			name: "first matching rule wins",
+			rules: []RewriteRule{
+				{Path: "$.store.bicycle", Rewrite: replace(`{"color": "blue"}`)},
+				{Path: "$.store.*", Rewrite: del},
+			},
+			input: `{"store": {"book": [], "bicycle": {"color": "red"}, "pen": {}}}`,
+			want:  `{"store": {"bicycle": {"color": "blue"}}}`,
+		},
+		{
+			name:  "root values",
+			rules: []RewriteRule{{Path: "$.", Rewrite: replace(`0`)}},
+			input: `1 "a" [2]`,
+			want:  `0 0 0`,
+		},
+		{
+			name:  "root array",
+			rules: []RewriteRule{{Path: "$.[*].a", Rewrite: replace(`true`)}},
+			input: `[{"a": false}, {"b": false}]`,
+			want:  `[{"a": true}, {"b": false}]`,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := NewStreamRewriter(strings.NewReader(tc.input), &out).Rewrite(tc.rules...)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, out.String())
+		})
+	}
+}
+
+func TestStreamRewriterSmallReads(t *testing.T) {
+	input := strings.Repeat(testdata, 20)
+	var out bytes.Buffer
+	rw := NewStreamRewriter(iotest.OneByteReader(strings.NewReader(input)), &out)
+	err := rw.RewritePath("$.store.book[*].isbn", func(key []byte, message json.RawMessage) (json.RawMessage, error) {
+		return nil, Delete
+	})
+	require.NoError(t, err)
+	want := strings.Replace(input, `"isbn": "0-553-21311-3",`, "", -1)
+	want = strings.Replace(want, `"isbn": "0-395-19395-8",`, "", -1)
+	require.Equal(t, strings.Join(strings.Fields(want), ""), strings.Join(strings.Fields(out.String()), ""))
+}
+
+func TestStreamRewriterErrors(t *testing.T) {
+	errFailed := errors.New("failed")
+	var testcases = []struct {
+		name  string
+		input string
+		fn    RewriteFunc
+		want  error
+	}{
+		{
+			name:  "syntax error",
+			input: `{"a": [1 2]}`,
+			want:  &SyntaxError{},
+		},
+		{
+			name:  "truncated input",
+			input: `{"a": [1, 2]`,
+			want:  io.ErrUnexpectedEOF,
+		},
+		{
+			name:  "rewrite error",
+			input: `{"a": [1, 2]}`,
+			fn: func(key []byte, message json.RawMessage) (json.RawMessage, error) {
+				return nil, errFailed
+			},
+			want: errFailed,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fn := tc.fn
+			if fn == nil {
+				fn = func(key []byte, message json.RawMessage) (json.RawMessage, error) {
+					return message, nil
+				}
+			}
+			err := NewStreamRewriter(strings.NewReader(tc.input), io.Discard).RewritePath("$.a[*]", fn)
+			require.Error(t, err)
+			require.IsType(t, tc.want, err)
+			if _, ok := tc.want.(*SyntaxError); !ok {
+				require.Equal(t, tc.want, err)
+			}
+		})
+	}
+}
+
+func TestStreamRewriterInvalidValue(t *testing.T) {
+	err := NewStreamRewriter(strings.NewReader(`{"a": [1, 2]}`), io.Discard).RewritePath("$.a[*]", func(key []byte, message json.RawMessage) (json.RawMessage, error) {
+		if string(message) == "2" {
+			return json.RawMessage(`{"b":`), nil
+		}
+		return message, nil
+	})
+	var serr *SyntaxError
+	require.True(t, errors.As(err, &serr), "%v", err)
+	require.Equal(t, "invalid rewritten value at $.a[1]", serr.Error())
+	require.Equal(t, int64(10), serr.Offset)
+}
+
+func TestStreamRewriterOptions(t *testing.T) {
+	keep := func(key []byte, message json.RawMessage) (json.RawMessage, error) {
+		return message, nil
+	}
+	var strictErr *StrictError
+	var limitErr *LimitError
+
+	// duplicates outside and inside the rewritten values
+	for _, input := range []string{`{"b": 1, "b": 2, "a": [1]}`, `{"a": [{"c": 1, "c": 2}]}`} {
+		err := NewStreamRewriter(strings.NewReader(input), io.Discard, Strict()).RewritePath("$.a[*]", keep)
+		require.ErrorAs(t, err, &strictErr, input)
+	}
+
+	err := NewStreamRewriter(strings.NewReader(`{"a": [[[1]]], "b": 1}`), io.Discard, WithLimits(Limits{MaxDepth: 3})).RewritePath("$.b", keep)
+	require.ErrorAs(t, err, &limitErr)
+	require.Equal(t, "MaxDepth", limitErr.Limit)
+
+	err = NewStreamRewriter(strings.NewReader(`{"a": "abcdef"}`), io.Discard, WithLimits(Limits{MaxStringLength: 4})).RewritePath("$.b", keep)
+	require.ErrorAs(t, err, &limitErr)
+	require.Equal(t, "$.a", limitErr.Path)
+
+	var out bytes.Buffer
+	err = NewStreamRewriter(strings.NewReader(`{"a": [1, 2,], /* c */ "b": 1}`), &out, Lenient(JSON5), Strict()).RewritePath("$.a[*]", keep)
+	require.NoError(t, err)
+	require.Equal(t, `{"a": [1, 2 ],         "b": 1}`, out.String())
+
+	err = NewStreamRewriter(bytes.NewReader(decodeHex(t, testMsgpack)), io.Discard, MessagePack(false)).RewritePath("$.a", keep)
+	require.Equal(t, errRewriteBinary, err)
+
+	// the rewriters built on the StreamRewriter
+	err = (&Redactor{Options: []Option{Strict()}}).Redact(strings.NewReader(`{"a": 1, "a": 2}`), io.Discard, "$.b")
+	require.ErrorAs(t, err, &strictErr)
+	err = ApplyPatch(strings.NewReader(`{"a": {"b": {"c": 1}}}`), io.Discard, []PatchOperation{{Op: "remove", Path: "/a/b"}}, WithLimits(Limits{MaxDepth: 2}))
+	require.ErrorAs(t, err, &limitErr)
+	err = ApplyMergePatch(strings.NewReader(`{"a": 1, "a": 2}`), io.Discard, json.RawMessage(`{"b": 1}`), Strict())
+	require.ErrorAs(t, err, &strictErr)
+}
@@ -19,6 +19,11 @@ type SyntaxError struct {
 	Offset  int64  // error occurred after reading Offset bytes
 	context string
 	quote   byte
+
+	// CompressedOffset is the amount of compressed input read when the error
+	// occurred, read-ahead included, so the error is located before it. It is
+	// only set when the input is decompressed.
+	CompressedOffset int64
 }
 
 func (e *SyntaxError) Error() string {
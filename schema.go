@@ -0,0 +1,279 @@
+package jspath
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"unicode/utf8"
+)
+
+// schemaSamples is the number of distinct sample values kept per path.
+const schemaSamples = 3
+
+// PathStats describes the values observed at a path, array indices collapsed to [*].
+type PathStats struct {
+	Path string
+	// Count is the number of values seen.
+	Count int64
+	// Types counts the values by JSON Schema type: object, array, string,
+	// integer, number, boolean and null. Nulls are counted in Types["null"].
+	Types map[string]int64
+
+	// MinLength and MaxLength are the lengths in characters of the strings.
+	MinLength, MaxLength int
+	// MinItems and MaxItems are the lengths of the arrays.
+	MinItems, MaxItems int
+	// Minimum and Maximum are the extreme numbers as they were written, empty
+	// once a number with an exponent out of range was seen.
+	Minimum, Maximum json.Number
+	// Samples holds the first distinct strings, numbers and booleans.
+	Samples []json.RawMessage
+
+	numbers    aggregator
+	unbounded  bool
+	properties []*PathStats
+	names      map[string]*PathStats
+	name       string
+	items      *PathStats
+}
+
+// NullFrequency returns the fraction of the values that are null.
+func (ps *PathStats) NullFrequency() float64 {
+	if ps.Count == 0 {
+		return 0
+	}
+	return float64(ps.Types["null"]) / float64(ps.Count)
+}
+
+// A Schema is the shape of a stream inferred by InferSchema.
+// It marshals to a JSON Schema document.
+type Schema struct {
+	// Paths holds the statistics of every path, in the order they were first seen.
+	Paths []*PathStats
+
+	root *PathStats
+}
+
+// InferSchema walks the whole stream read from r and infers its schema.
+// All the documents of the stream are described by the same schema.
+func InferSchema(r io.Reader, opts ...Option) (*Schema, error) {
+	inferrer := &schemaInferrer{}
+	dec := NewStreamDecoder(r, opts...)
+	dec.observer = inferrer
+	if err := dec.Decode(); err != nil {
+		return nil, err
+	}
+	return &inferrer.schema, nil
+}
+
+type schemaFrame struct {
+	stats   *PathStats
+	delim   byte
+	pathLen int
+	length  int
+}
+
+// schemaInferrer is the tokenObserver collecting the statistics of the paths.
+type schemaInferrer struct {
+	schema Schema
+	frames []schemaFrame
+}
+
+func (s *schemaInferrer) openContainer(delim byte, path []byte) error {
+	stats := s.stats(path)
+	if delim == '[' {
+		stats.add("array", nil)
+	} else {
+		stats.add("object", nil)
+	}
+	s.frames = append(s.frames, schemaFrame{stats: stats, delim: delim, pathLen: len(path)})
+	return nil
+}
+
+func (s *schemaInferrer) closeContainer(delim byte) error {
+	frame := s.frames[len(s.frames)-1]
+	s.frames = s.frames[:len(s.frames)-1]
+	if delim != ']' {
+		return nil
+	}
+	stats := frame.stats
+	if stats.Types["array"] == 1 || frame.length < stats.MinItems {
+		stats.MinItems = frame.length
+	}
+	if frame.length > stats.MaxItems {
+		stats.MaxItems = frame.length
+	}
+	return nil
+}
+
+func (s *schemaInferrer) literal(path []byte, value []byte) error {
+	stats := s.stats(path)
+	switch value[0] {
+	case '"':
+		stats.add("string", value)
+		n := utf8.RuneCountInString(unquoteString(value))
+		if stats.Types["string"] == 1 || n < stats.MinLength {
+			stats.MinLength = n
+		}
+		if n > stats.MaxLength {
+			stats.MaxLength = n
+		}
+	case 't', 'f':
+		stats.add("boolean", value)
+	case 'n':
+		stats.add("null", nil)
+	default:
+		if bytes.IndexAny(value, ".eE") >= 0 {
+			stats.add("number", value)
+		} else {
+			stats.add("integer", value)
+		}
+		a := &stats.numbers
+		if stats.unbounded || a.add(value) != nil {
+			// the number is out of range, the extremes are unknown
+			stats.unbounded = true
+			stats.Minimum, stats.Maximum = "", ""
+			return nil
+		}
+		if a.min.Cmp(&a.n) == 0 {
+			stats.Minimum = json.Number(value)
+		}
+		if a.max.Cmp(&a.n) == 0 {
+			stats.Maximum = json.Number(value)
+		}
+	}
+	return nil
+}
+
+// stats returns the statistics of the value at path, which is a member or an
+// element of the innermost open container.
+func (s *schemaInferrer) stats(path []byte) *PathStats {
+	if len(s.frames) == 0 {
+		if s.schema.root == nil {
+			s.schema.root = s.newStats("$", "")
+		}
+		return s.schema.root
+	}
+	frame := &s.frames[len(s.frames)-1]
+	parent := frame.stats
+	if frame.delim == '[' {
+		frame.length++
+		if parent.items == nil {
+			if parent == s.schema.root {
+				parent.items = s.newStats("$.[*]", "")
+			} else {
+				parent.items = s.newStats(parent.Path+"[*]", "")
+			}
+		}
+		return parent.items
+	}
+	key := path[frame.pathLen+1:]
+	if child, ok := parent.names[string(key)]; ok {
+		return child
+	}
+	child := s.newStats(parent.Path+"."+string(key), string(key))
+	if parent.names == nil {
+		parent.names = map[string]*PathStats{}
+	}
+	parent.names[child.name] = child
+	parent.properties = append(parent.properties, child)
+	return child
+}
+
+func (s *schemaInferrer) newStats(path, name string) *PathStats {
+	stats := &PathStats{Path: path, name: name, Types: map[string]int64{}}
+	s.schema.Paths = append(s.schema.Paths, stats)
+	return stats
+}
+
+func (ps *PathStats) add(typ string, sample []byte) {
+	ps.Count++
+	ps.Types[typ]++
+	if sample == nil || len(ps.Samples) == schemaSamples {
+		return
+	}
+	for _, s := range ps.Samples {
+		if bytes.Equal(s, sample) {
+			return
+		}
+	}
+	ps.Samples = append(ps.Samples, append(json.RawMessage(nil), sample...))
+}
+
+// MarshalJSON encodes the schema as a JSON Schema (draft 2020-12) document.
+// The number of values and the null frequency of every path are reported
+// with the x-count and x-nullFrequency annotations.
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	buf := []byte(`{"$schema":"https://json-schema.org/draft/2020-12/schema"`)
+	if s.root != nil {
+		buf = s.root.appendSchema(append(buf, ','))
+	}
+	return append(buf, '}'), nil
+}
+
+var schemaTypes = []string{"object", "array", "string", "integer", "number", "boolean", "null"}
+
+// appendSchema appends the keywords describing ps to buf, type first.
+func (ps *PathStats) appendSchema(buf []byte) []byte {
+	var types []string
+	for _, typ := range schemaTypes {
+		if ps.Types[typ] == 0 || typ == "integer" && ps.Types["number"] > 0 {
+			continue
+		}
+		types = append(types, typ)
+	}
+	var typ []byte
+	if len(types) == 1 {
+		typ, _ = json.Marshal(types[0])
+	} else {
+		typ, _ = json.Marshal(types)
+	}
+	buf = append(append(buf, `"type":`...), typ...)
+	if ps.Types["object"] > 0 {
+		buf = append(buf, `,"properties":{`...)
+		var required []string
+		for i, child := range ps.properties {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			key, _ := json.Marshal(child.name)
+			buf = append(append(buf, key...), ":{"...)
+			buf = append(child.appendSchema(buf), '}')
+			if child.Count == ps.Types["object"] {
+				required = append(required, child.name)
+			}
+		}
+		buf = append(buf, '}')
+		if required != nil {
+			buf = appendKeyword(buf, "required", required)
+		}
+	}
+	if ps.Types["array"] > 0 {
+		if ps.items != nil {
+			buf = append(ps.items.appendSchema(append(buf, `,"items":{`...)), '}')
+		}
+		buf = appendKeyword(buf, "minItems", ps.MinItems)
+		buf = appendKeyword(buf, "maxItems", ps.MaxItems)
+	}
+	if ps.Types["string"] > 0 {
+		buf = appendKeyword(buf, "minLength", ps.MinLength)
+		buf = appendKeyword(buf, "maxLength", ps.MaxLength)
+	}
+	if ps.Minimum != "" {
+		buf = appendKeyword(buf, "minimum", ps.Minimum)
+		buf = appendKeyword(buf, "maximum", ps.Maximum)
+	}
+	if ps.Samples != nil {
+		buf = appendKeyword(buf, "examples", ps.Samples)
+	}
+	buf = appendKeyword(buf, "x-count", ps.Count)
+	return appendKeyword(buf, "x-nullFrequency", ps.NullFrequency())
+}
+
+// appendKeyword appends a keyword following another one to buf.
+func appendKeyword(buf []byte, keyword string, v interface{}) []byte {
+	value, _ := json.Marshal(v)
+	buf = append(buf, ',', '"')
+	buf = append(append(buf, keyword...), '"', ':')
+	return append(buf, value...)
+}
@@ -0,0 +1,87 @@
+package jspath
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInferSchema(t *testing.T) {
+	schema, err := InferSchema(strings.NewReader(testdata + `{"store": null, "tags": ["aé", 1, 2.5]} {"tags": []}`))
+	require.NoError(t, err)
+
+	var paths []string
+	stats := map[string]*PathStats{}
+	for _, ps := range schema.Paths {
+		paths = append(paths, ps.Path)
+		stats[ps.Path] = ps
+	}
+	require.Equal(t, []string{
+		"$", "$.store", "$.store.book", "$.store.book[*]",
+		"$.store.book[*].category", "$.store.book[*].author", "$.store.book[*].title", "$.store.book[*].price", "$.store.book[*].isbn",
+		"$.store.bicycle", "$.store.bicycle.color", "$.store.bicycle.price", "$.expensive",
+		"$.tags", "$.tags[*]",
+	}, paths)
+
+	store := stats["$.store"]
+	require.Equal(t, int64(2), store.Count)
+	require.Equal(t, 0.5, store.NullFrequency())
+
+	price := stats["$.store.book[*].price"]
+	require.Equal(t, map[string]int64{"number": 4}, price.Types)
+	require.Equal(t, json.Number("8.95"), price.Minimum)
+	require.Equal(t, json.Number("22.99"), price.Maximum)
+	require.Equal(t, []json.RawMessage{json.RawMessage("8.95"), json.RawMessage("12.99"), json.RawMessage("8.99")}, price.Samples)
+
+	require.Equal(t, 9, stats["$.store.book[*].title"].MinLength)
+	require.Equal(t, 22, stats["$.store.book[*].title"].MaxLength)
+
+	tags := stats["$.tags"]
+	require.Equal(t, 0, tags.MinItems)
+	require.Equal(t, 3, tags.MaxItems)
+	items := stats["$.tags[*]"]
+	require.Equal(t, map[string]int64{"string": 1, "integer": 1, "number": 1}, items.Types)
+	require.Equal(t, 2, items.MinLength)
+}
+
+func TestInferSchemaJSON(t *testing.T) {
+	schema, err := InferSchema(strings.NewReader(`{"a": 1, "b": ["x"]} {"a": null}`))
+	require.NoError(t, err)
+	out, err := json.Marshal(schema)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"type": "object",
+		"properties": {
+			"a": {"type": ["integer", "null"], "minimum": 1, "maximum": 1, "examples": [1], "x-count": 2, "x-nullFrequency": 0.5},
+			"b": {
+				"type": "array",
+				"items": {"type": "string", "minLength": 1, "maxLength": 1, "examples": ["x"], "x-count": 1, "x-nullFrequency": 0},
+				"minItems": 1, "maxItems": 1, "x-count": 1, "x-nullFrequency": 0
+			}
+		},
+		"required": ["a"],
+		"x-count": 2,
+		"x-nullFrequency": 0
+	}`, string(out))
+
+	schema, err = InferSchema(strings.NewReader(`[1, [true]]`))
+	require.NoError(t, err)
+	require.Equal(t, "$.[*][*]", schema.Paths[2].Path)
+}
+
+func TestInferSchemaNumberRange(t *testing.T) {
+	schema, err := InferSchema(strings.NewReader(`{"a": 1} {"a": 1e300000000} {"a": 2}`))
+	require.NoError(t, err)
+	a := schema.Paths[1]
+	require.Equal(t, "$.a", a.Path)
+	require.Equal(t, map[string]int64{"integer": 2, "number": 1}, a.Types)
+	require.Empty(t, a.Minimum)
+	require.Empty(t, a.Maximum)
+
+	out, err := json.Marshal(schema)
+	require.NoError(t, err)
+	require.NotContains(t, string(out), "minimum")
+}
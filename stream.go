@@ -46,28 +46,71 @@ type StreamDecoder struct {
 
 	done chan struct{}
 	path pathBuilder
+	// key holds the last unescaped object key.
+	key []byte
+
+	onCheckpoint func(cp Checkpoint) error
+	observer     tokenObserver
+
+	codecs     []Codec
+	sniffed    bool
+	compressed *decompressReader
+	dialect    Dialect
+	checkers   []valueChecker
+	match      Match
+	captures   Captures
+	limits     Limits
+	checkPath  []byte
+
+	format     binaryFormat
+	rawBinary  bool
+	transcoded []byte
 }
 
+// A tokenObserver is notified of the containers the decoder walks through,
+// for consumers that need the structure around the matched values.
+type tokenObserver interface {
+	// openContainer is called when entering an unmatched object or array at path.
+	openContainer(delim byte, path []byte) error
+	// closeContainer is called with the closing delimiter when leaving it.
+	closeContainer(delim byte) error
+	// literal is called with the unmatched strings, numbers, booleans and nulls.
+	literal(path []byte, value []byte) error
+}
+
+// An Option configures a StreamDecoder.
+type Option func(dec *StreamDecoder)
+
 // NewStreamDecoder returns a new StreamDecoder that reads from r.
 //
 // The StreamDecoder introduces its own buffering and may
 // read data from r beyond the JSON values requested.
-func NewStreamDecoder(r io.Reader) *StreamDecoder {
-	return &StreamDecoder{r: r, path: newPathBuilder(), done: make(chan struct{}, 0), context: context.Background()}
+func NewStreamDecoder(r io.Reader, opts ...Option) *StreamDecoder {
+	dec := &StreamDecoder{r: r, path: newPathBuilder(), done: make(chan struct{}, 0), context: context.Background()}
+	for _, opt := range opts {
+		opt(dec)
+	}
+	return dec
 }
 
 func (dec *StreamDecoder) WithContext(ctx context.Context) {
 	dec.context = ctx
 }
 
+// WithCheckpoint registers fn to be called with a Checkpoint after every matched
+// value has been successfully handled. Returning an error stops the decoding.
+func (dec *StreamDecoder) WithCheckpoint(fn func(cp Checkpoint) error) {
+	dec.onCheckpoint = fn
+}
+
 func (dec *StreamDecoder) Decode(itemDecoders ...UnmarshalerStream) (err error) {
 	var decoders = make([]decoder, 0, len(itemDecoders))
 	for i := range itemDecoders {
-		matcher, err := dec.compilePath(itemDecoders[i].AtPath())
+		d, err := dec.newDecoder(itemDecoders[i])
 		if err != nil {
 			return err
 		}
-		decoders = append(decoders, decoder{unmarshaler: itemDecoders[i], matcher: matcher})
+		decoders = append(decoders, d)
 	}
 	go dec.decode(decoders...)
 	<-dec.Done()
@@ -75,11 +118,11 @@ func (dec *StreamDecoder) Decode(itemDecoders ...UnmarshalerStream) (err error)
 }
 
 func (dec *StreamDecoder) DecodePath(jsPath string, onPath func(key []byte, message json.RawMessage) error) (err error) {
-	matcher, err := dec.compilePath(jsPath)
+	d, err := dec.newDecoder(NewRawStreamUnmarshaler(jsPath, onPath))
 	if err != nil {
 		return err
 	}
-	go dec.decode(decoder{unmarshaler: NewRawStreamUnmarshaler(jsPath, onPath), matcher: matcher})
+	go dec.decode(d)
 	<-dec.Done()
 	return dec.err
 }
@@ -88,7 +131,7 @@ func (dec *StreamDecoder) Done() <-chan struct{} {
 	return dec.done
 }
 
-//Err can only be called after the decode finish
+// Err can only be called after the decode finish
 func (dec *StreamDecoder) Err() error {
 	return dec.err
 }
@@ -100,7 +143,18 @@ func (dec *StreamDecoder) Reset(reader io.Reader) (err error) {
 	default:
 		panic("cannot call reset while decoder is running")
 	}
+	dec.reset(reader)
+	for _, c := range dec.checkers {
+		c.reset()
+	}
+	return
+}
+
+// reset prepares the decoder to read a new input from reader. The checkers
+// are left as they are, the embedded decoders share those of the outer one.
+func (dec *StreamDecoder) reset(reader io.Reader) {
 	dec.done = make(chan struct{})
+	dec.err = nil
 	dec.path.Reset()
 	dec.tokenStack = dec.tokenStack[0:0]
 	dec.tokenState = 0
@@ -110,13 +164,25 @@ func (dec *StreamDecoder) Reset(reader io.Reader) (err error) {
 	dec.scanned = 0
 	dec.scanp = 0
 	dec.r = reader
-	return
+	dec.sniffed = false
+	dec.compressed = nil
 }
 
 func (dec *StreamDecoder) decode(decoders ...decoder) {
 	defer func() {
+		if serr, ok := dec.err.(*SyntaxError); ok && dec.compressed != nil {
+			serr.CompressedOffset = dec.compressed.in.n
+		}
 		close(dec.done)
 	}()
+	if dec.format != nil {
+		dec.err = dec.decodeBinary(decoders)
+		return
+	}
+	if dec.onCheckpoint != nil && !dec.checkpointable() {
+		dec.err = errCheckpointUnsupported
+		return
+	}
 	for {
 		select {
 		case <-dec.context.Done():
@@ -141,8 +207,17 @@ func (dec *StreamDecoder) decode(decoders ...decoder) {
 			if dec.more() {
 				curPath := dec.path.PathBytes()
 				dec.path.StartArray()
-				match, itemDecoder := matcher(decoders).match(BytesToString(curPath))
+				match, itemDecoder := matcher(decoders).match(BytesToString(curPath), literalKind(c))
+				dec.captures = itemDecoder.captured()
 				if match {
+					dec.path.EndArray()
+					if r, ok := itemDecoder.unmarshaler.(ReaderUnmarshalerStream); ok {
+						if err := dec.handleReader(r, curPath); err != nil {
+							dec.err = err
+							return
+						}
+						continue
+					}
 					bytes, err := dec.decodeBytes()
 					if err != nil {
 						if err == io.EOF {
@@ -152,10 +227,13 @@ func (dec *StreamDecoder) decode(decoders ...decoder) {
 						return
 					}
 					//update state
-					dec.path.EndArray()
 					dec.tokenValueEnd()
 
-					if err := itemDecoder.unmarshaler.UnmarshalStream(curPath, bytes); err != nil {
+					if err := dec.checkValue(curPath, bytes); err != nil {
+						dec.err = err
+						return
+					}
+					if err := dec.handle(itemDecoder, curPath, bytes); err != nil {
 						dec.err = err
 						return
 					}
@@ -165,6 +243,18 @@ func (dec *StreamDecoder) decode(decoders ...decoder) {
 			dec.scanp++
 			dec.tokenStack = append(dec.tokenStack, dec.tokenState)
 			dec.tokenState = tokenArrayStart
+			arrayPath := dec.path.PathBytes()
+			arrayPath = arrayPath[:len(arrayPath)-dec.path.stackSegmentsSizes.Peek()]
+			if dec.observer != nil {
+				if err := dec.observer.openContainer(c, arrayPath); err != nil {
+					dec.err = err
+					return
+				}
+			}
+			if err := dec.checkOpen(c, arrayPath, dec.offset()-1); err != nil {
+				dec.err = err
+				return
+			}
 			continue
 		case ']':
 			if dec.tokenState != tokenArrayStart && dec.tokenState != tokenArrayComma {
@@ -174,6 +264,13 @@ func (dec *StreamDecoder) decode(decoders ...decoder) {
 			dec.scanp++
 			dec.tokenState = dec.tokenStack[len(dec.tokenStack)-1]
 			dec.tokenStack = dec.tokenStack[:len(dec.tokenStack)-1]
+			if dec.observer != nil {
+				if err := dec.observer.closeContainer(c); err != nil {
+					dec.err = err
+					return
+				}
+			}
+			dec.checkClose('[')
 			dec.path.EndObject()
 			dec.tokenValueEnd()
 			continue
@@ -185,8 +282,16 @@ func (dec *StreamDecoder) decode(decoders ...decoder) {
 			}
 			if dec.more() {
 				curPath := dec.path.PathBytes()
-				match, itemDecoder := matcher(decoders).match(BytesToString(curPath))
+				match, itemDecoder := matcher(decoders).match(BytesToString(curPath), literalKind(c))
+				dec.captures = itemDecoder.captured()
 				if match {
+					if r, ok := itemDecoder.unmarshaler.(ReaderUnmarshalerStream); ok {
+						if err := dec.handleReader(r, curPath); err != nil {
+							dec.err = err
+							return
+						}
+						continue
+					}
 					bytes, err := dec.decodeBytes()
 					if err != nil {
 						if err == io.EOF {
@@ -196,7 +301,11 @@ func (dec *StreamDecoder) decode(decoders ...decoder) {
 						return
 					}
 
-					if err := itemDecoder.unmarshaler.UnmarshalStream(curPath, bytes); err != nil {
+					if err := dec.checkValue(curPath, bytes); err != nil {
+						dec.err = err
+						return
+					}
+					if err := dec.handle(itemDecoder, curPath, bytes); err != nil {
 						dec.err = err
 						return
 					}
@@ -207,6 +316,16 @@ func (dec *StreamDecoder) decode(decoders ...decoder) {
 			dec.scanp++
 			dec.tokenStack = append(dec.tokenStack, dec.tokenState)
 			dec.tokenState = tokenObjectStart
+			if dec.observer != nil {
+				if err := dec.observer.openContainer(c, dec.path.PathBytes()); err != nil {
+					dec.err = err
+					return
+				}
+			}
+			if err := dec.checkOpen(c, dec.path.PathBytes(), dec.offset()-1); err != nil {
+				dec.err = err
+				return
+			}
 			dec.path.StartObject()
 			continue
 
@@ -218,7 +337,14 @@ func (dec *StreamDecoder) decode(decoders ...decoder) {
 			dec.scanp++
 			dec.tokenState = dec.tokenStack[len(dec.tokenStack)-1]
 			dec.tokenStack = dec.tokenStack[:len(dec.tokenStack)-1]
+			if dec.observer != nil {
+				if err := dec.observer.closeContainer(c); err != nil {
+					dec.err = err
+					return
+				}
+			}
 			dec.path.EndObject()
+			dec.checkClose('{')
 
 			dec.tokenValueEnd()
 			continue
@@ -257,7 +383,11 @@ func (dec *StreamDecoder) decode(decoders ...decoder) {
 					return
 				}
 				dec.tokenState = tokenObjectColon
-				dec.path.SetObjectKey(keyBytes[1 : len(keyBytes)-1])
+				if err := dec.checkMember(keyBytes, dec.offset()-int64(len(keyBytes))); err != nil {
+					dec.err = err
+					return
+				}
+				dec.setObjectKey(keyBytes)
 				continue
 			}
 			fallthrough
@@ -268,13 +398,31 @@ func (dec *StreamDecoder) decode(decoders ...decoder) {
 				return
 			}
 
+			curPath := dec.path.PathBytes()
+			match, itemDecoder := matcher(decoders).match(BytesToString(curPath), literalKind(c))
+			dec.captures = itemDecoder.captured()
+			if r, ok := itemDecoder.unmarshaler.(ReaderUnmarshalerStream); match && ok {
+				if err := dec.handleReader(r, curPath); err != nil {
+					dec.err = err
+					return
+				}
+				continue
+			}
 			if bytes, err := dec.decodeBytes(); err != nil {
 				dec.err = err
 				return
 			} else {
-				curPath := dec.path.PathBytes()
-				if match, itemDecoder := matcher(decoders).match(BytesToString(curPath)); match {
-					if err := itemDecoder.unmarshaler.UnmarshalStream(curPath, bytes); err != nil {
+				if err := dec.checkValue(curPath, bytes); err != nil {
+					dec.err = err
+					return
+				}
+				if match {
+					if err := dec.handle(itemDecoder, curPath, bytes); err != nil {
+						dec.err = err
+						return
+					}
+				} else if dec.observer != nil {
+					if err := dec.observer.literal(curPath, bytes); err != nil {
 						dec.err = err
 						return
 					}
@@ -286,6 +434,24 @@ func (dec *StreamDecoder) decode(decoders ...decoder) {
 	return
 }
 
+// handle hands a matched value to its unmarshaler and, once it has been
+// accepted, reports a checkpoint positioned right after it.
+func (dec *StreamDecoder) handle(d decoder, key []byte, message json.RawMessage) error {
+	if mu, ok := d.unmarshaler.(MatchUnmarshalerStream); ok {
+		dec.match = Match{Kind: literalKind(message[0]), Offset: dec.offset() - int64(len(message)), Length: len(message), Captures: dec.captures}
+		dec.path.match(&dec.match)
+		if err := mu.UnmarshalMatch(&dec.match, message); err != nil {
+			return err
+		}
+	} else if err := d.unmarshaler.UnmarshalStream(key, message); err != nil {
+		return err
+	}
+	if dec.onCheckpoint != nil {
+		return dec.onCheckpoint(dec.checkpoint())
+	}
+	return nil
+}
+
 func (dec *StreamDecoder) decodeBytes() ([]byte, error) {
 	if dec.err != nil {
 		return nil, dec.err
@@ -361,13 +527,33 @@ Input:
 		}
 
 		n := scanp - dec.scanp
+		if err := dec.checkValueSize(n); err != nil {
+			dec.err = err
+			return 0, err
+		}
 		err = dec.refill()
 		scanp = dec.scanp + n
 	}
+	if err := dec.checkValueSize(scanp - dec.scanp); err != nil {
+		dec.err = err
+		return 0, err
+	}
 	return scanp - dec.scanp, nil
 }
 
 func (dec *StreamDecoder) refill() error {
+	if !dec.sniffed {
+		dec.sniffed = true
+		if len(dec.codecs) > 0 {
+			if err := dec.sniff(); err != nil {
+				return err
+			}
+		}
+		if dec.dialect != 0 {
+			dec.r = newLenientReader(dec.r, dec.dialect)
+		}
+	}
+
 	// Make room to read more into the buffer.
 	// First slide down data already consumed.
 	if dec.scanp > 0 {
@@ -386,8 +572,23 @@ func (dec *StreamDecoder) refill() error {
 	}
 
 	// Read. Delay error for next iteration (after scan).
-	n, err := dec.r.Read(dec.buf[len(dec.buf):cap(dec.buf)])
+	buf := dec.buf[len(dec.buf):cap(dec.buf)]
+	max := dec.limits.MaxTotalBytes
+	if max > 0 {
+		// one byte past the limit tells whether it is exceeded
+		remaining := max - dec.scanned - int64(len(dec.buf))
+		if remaining < 0 {
+			return dec.limitError("MaxTotalBytes", max)
+		}
+		if remaining < int64(len(buf)) {
+			buf = buf[:remaining+1]
+		}
+	}
+	n, err := dec.r.Read(buf)
 	dec.buf = dec.buf[0 : len(dec.buf)+n]
+	if max > 0 && dec.scanned+int64(len(dec.buf)) > max {
+		return dec.limitError("MaxTotalBytes", max)
+	}
 
 	return err
 }
@@ -457,14 +658,14 @@ func (dec *StreamDecoder) tokenError(c byte) error {
 		context = " looking for beginning of value"
 	case tokenArrayComma:
 		context = " after array element"
-	case tokenObjectKey:
+	case tokenObjectStart, tokenObjectKey:
 		context = " looking for beginning of object key string"
 	case tokenObjectColon:
 		context = " after object key"
 	case tokenObjectComma:
 		context = " after object key:value pair"
 	}
-	return &SyntaxError{msg: "invalid character " + quoteChar(c) + " " + context, Offset: dec.offset()}
+	return &SyntaxError{msg: "invalid character " + quoteChar(c) + context, Offset: dec.offset()}
 }
 
 // more reports whether there is another element in the
@@ -489,6 +690,8 @@ func (dec *StreamDecoder) peek() (byte, error) {
 		if err != nil {
 			return 0, err
 		}
+		// the whitespace is consumed, so that it does not grow the buffer
+		dec.scanp = len(dec.buf)
 		err = dec.refill()
 	}
 }
@@ -508,12 +711,13 @@ func (dec *StreamDecoder) compilePath(jsPath string) (func(curPath, jsPath strin
 			return re.Match(curPath)
 		}, nil
 	}
+	literal := unescapePath(jsPath)
 	return func(curPath string, jsPath string) bool {
 		//exception for root path
 		if curPath == "$" && jsPath == "$." {
 			return true
 		}
-		return curPath == jsPath
+		return curPath == literal
 	}, nil
 }
 
@@ -526,12 +730,46 @@ func escapeGlob(jsPath string) string {
 type decoder struct {
 	unmarshaler UnmarshalerStream
 	matcher     func(curPath, jsPath string) bool
+	kinds       kindSet
+	// captures is the pattern of the paths with named wildcards.
+	captures *capturePattern
+}
+
+// newDecoder compiles the path of u.
+func (dec *StreamDecoder) newDecoder(u UnmarshalerStream) (decoder, error) {
+	if embeddedIndex(u.AtPath()) >= 0 {
+		var err error
+		if u, err = dec.newEmbeddedUnmarshaler(u); err != nil {
+			return decoder{}, err
+		}
+	}
+	var d decoder
+	if hasCaptures(u.AtPath()) {
+		p, err := compileCaptures(u.AtPath())
+		if err != nil {
+			return decoder{}, err
+		}
+		d = decoder{unmarshaler: u, matcher: dec.captureMatcher(p), captures: p}
+	} else {
+		matcher, err := dec.compilePath(u.AtPath())
+		if err != nil {
+			return decoder{}, err
+		}
+		d = decoder{unmarshaler: u, matcher: matcher}
+	}
+	if mu, ok := u.(MatchUnmarshalerStream); ok {
+		d.kinds = newKindSet(mu.Kinds())
+	}
+	return d, nil
 }
 
 type matcher []decoder
 
-func (matchers matcher) match(curPath string) (bool, decoder) {
+func (matchers matcher) match(curPath string, kind Kind) (bool, decoder) {
 	for i := range matchers {
+		if !matchers[i].kinds.has(kind) {
+			continue
+		}
 		match := matchers[i].matcher(curPath, matchers[i].unmarshaler.AtPath())
 		if match {
 			return true, matchers[i]
@@ -502,3 +502,23 @@ func TestDecodeSimpleTypesAndReset(t *testing.T) {
 		})
 	}
 }
+
+func TestDecodeResetCheckers(t *testing.T) {
+	handler := func(key []byte, message json.RawMessage) error {
+		return nil
+	}
+	dec := NewStreamDecoder(strings.NewReader(`{"a": {"b": {"c": 1}}}`), Strict(), WithLimits(Limits{MaxDepth: 2, MaxMembers: 1}))
+	var limitErr *LimitError
+	require.ErrorAs(t, dec.DecodePath("$.x", handler), &limitErr)
+	require.Equal(t, "MaxDepth", limitErr.Limit)
+
+	// the containers left open by the failed decode are forgotten
+	dec.Reset(strings.NewReader(`{"a": {"b": 1}} {"a": 1}`))
+	require.NoError(t, dec.DecodePath("$.x", handler))
+
+	dec.Reset(strings.NewReader(`{"a": {"b": 1, "b": 2}}`))
+	var strictErr *StrictError
+	require.ErrorAs(t, dec.DecodePath("$.x", handler), &strictErr)
+	dec.Reset(strings.NewReader(`{"b": 1}`))
+	require.NoError(t, dec.DecodePath("$.x", handler))
+}
@@ -0,0 +1,146 @@
+package jspath
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// A StrictError reports input rejected by a strict StreamDecoder.
+type StrictError struct {
+	// Path is the path of the offending string or member.
+	Path string
+	// Offset is the input offset of the offending string.
+	Offset  int64
+	Message string
+}
+
+func (e *StrictError) Error() string {
+	return fmt.Sprintf("jspath: %s at offset %d: %s", e.Path, e.Offset, e.Message)
+}
+
+// Strict makes the StreamDecoder reject strings holding invalid UTF-8 or
+// unpaired surrogate escapes, and objects holding the same key twice, with a
+// *StrictError. Keys are compared unescaped, so "a" and "\u0061" are duplicates.
+//
+// Matched values are checked before they are handed over. MessagePack and CBOR
+// input is checked as well, map keys being compared as rendered in paths.
+func Strict() Option {
+	return func(dec *StreamDecoder) {
+		dec.checkers = append(dec.checkers, &strictChecker{})
+	}
+}
+
+// strictChecker tracks the keys of the objects being decoded, one set per depth.
+type strictChecker struct {
+	keys  []map[string]struct{}
+	depth int
+	key   []byte
+}
+
+func (s *strictChecker) open(delim byte, path []byte, offset int64) error {
+	if delim != '{' {
+		return nil
+	}
+	if s.depth < len(s.keys) {
+		for k := range s.keys[s.depth] {
+			delete(s.keys[s.depth], k)
+		}
+	} else {
+		s.keys = append(s.keys, map[string]struct{}{})
+	}
+	s.depth++
+	return nil
+}
+
+func (s *strictChecker) close(delim byte) {
+	if delim == '{' && s.depth > 0 {
+		s.depth--
+	}
+}
+
+func (s *strictChecker) member(path []byte, quoted []byte, offset int64) error {
+	if err := checkStrictString(path, quoted, offset); err != nil {
+		return err
+	}
+	s.key = appendUnescaped(s.key[0:0], quoted[1:len(quoted)-1])
+	keys := s.keys[s.depth-1]
+	if _, ok := keys[string(s.key)]; ok {
+		return &StrictError{Path: string(path), Offset: offset, Message: "duplicate key " + strconv.Quote(string(s.key))}
+	}
+	keys[string(s.key)] = struct{}{}
+	return nil
+}
+
+func (s *strictChecker) save(cp *Checkpoint) {
+	cp.Keys = make([][]string, s.depth)
+	for i, keys := range s.keys[:s.depth] {
+		cp.Keys[i] = make([]string, 0, len(keys))
+		for k := range keys {
+			cp.Keys[i] = append(cp.Keys[i], k)
+		}
+		sort.Strings(cp.Keys[i])
+	}
+}
+
+func (s *strictChecker) restore(cp *Checkpoint) error {
+	if len(cp.Keys) != cp.openObjects() {
+		return errInvalidCheckpoint
+	}
+	s.depth = 0
+	for _, keys := range cp.Keys {
+		s.open('{', nil, 0)
+		for _, k := range keys {
+			s.keys[s.depth-1][k] = struct{}{}
+		}
+	}
+	return nil
+}
+
+func (s *strictChecker) reset() {
+	s.depth = 0
+}
+
+func (s *strictChecker) str(path []byte, quoted []byte, offset int64) error {
+	return checkStrictString(path, quoted, offset)
+}
+
+// checkStrictString checks the quoted JSON string for invalid UTF-8 and unpaired surrogates.
+func checkStrictString(path []byte, quoted []byte, offset int64) error {
+	for i := 1; i < len(quoted)-1; {
+		c := quoted[i]
+		if c >= utf8.RuneSelf {
+			r, size := utf8.DecodeRune(quoted[i:])
+			if r == utf8.RuneError && size == 1 {
+				return &StrictError{Path: string(path), Offset: offset + int64(i), Message: "invalid UTF-8 in string"}
+			}
+			i += size
+			continue
+		}
+		if c != '\\' {
+			i++
+			continue
+		}
+		if quoted[i+1] != 'u' {
+			i += 2
+			continue
+		}
+		r := hexRune(quoted[i+2:])
+		switch {
+		case r >= 0xd800 && r < 0xdc00:
+			if i+12 > len(quoted)-1 || quoted[i+6] != '\\' || quoted[i+7] != 'u' {
+				return &StrictError{Path: string(path), Offset: offset + int64(i), Message: "unpaired surrogate in string"}
+			}
+			if low := hexRune(quoted[i+8:]); low < 0xdc00 || low >= 0xe000 {
+				return &StrictError{Path: string(path), Offset: offset + int64(i), Message: "unpaired surrogate in string"}
+			}
+			i += 12
+		case r >= 0xdc00 && r < 0xe000:
+			return &StrictError{Path: string(path), Offset: offset + int64(i), Message: "unpaired surrogate in string"}
+		default:
+			i += 6
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package jspath
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodePathStrict(t *testing.T) {
+	var testcases = []struct {
+		name    string
+		input   string
+		path    string
+		errPath string
+		offset  int64
+		message string
+	}{
+		{
+			name:    "duplicate key",
+			input:   `{"a": 1, "b": 2, "a": 3}`,
+			path:    "$.none",
+			errPath: "$.a",
+			offset:  17,
+			message: `duplicate key "a"`,
+		},
+		{
+			name:    "escaped duplicate key",
+			input:   `{"x": {"a": 1}, "y": {"a": 1, "\u0061": 2}}`,
+			path:    "$.none",
+			errPath: "$.y.a",
+			offset:  30,
+			message: `duplicate key "a"`,
+		},
+		{
+			name:    "duplicate key in matched value",
+			input:   `{"items": [{"id": 1}, {"id": 2, "id": 3}]}`,
+			path:    "$.items",
+			errPath: "$.items[1].id",
+			offset:  32,
+			message: `duplicate key "id"`,
+		},
+		{
+			name:    "invalid utf-8",
+			input:   "[\"ok\", \"a\xffb\"]",
+			path:    "$.none",
+			errPath: "$.[1]",
+			offset:  9,
+			message: "invalid UTF-8 in string",
+		},
+		{
+			name:    "invalid utf-8 key in matched value",
+			input:   "[{\"\xc3\": 1}]",
+			path:    "$.[*]",
+			errPath: "$.[0].\xc3",
+			offset:  3,
+			message: "invalid UTF-8 in string",
+		},
+		{
+			name:    "lone high surrogate",
+			input:   `{"a": "x\ud800"}`,
+			path:    "$.a",
+			errPath: "$.a",
+			offset:  8,
+			message: "unpaired surrogate in string",
+		},
+		{
+			name:    "lone low surrogate",
+			input:   `{"a": ["\udc00😀"]}`,
+			path:    "$",
+			errPath: "$.a[0]",
+			offset:  8,
+			message: "unpaired surrogate in string",
+		},
+		{
+			name:    "high surrogate without low",
+			input:   `{"\ud83dA": 1}`,
+			path:    "$.none",
+			errPath: "$.�A",
+			offset:  2,
+			message: "unpaired surrogate in string",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := NewStreamDecoder(strings.NewReader(tc.input), Strict()).DecodePath(tc.path, func(key []byte, message json.RawMessage) error {
+				return nil
+			})
+			var serr *StrictError
+			require.True(t, errors.As(err, &serr), "%v", err)
+			require.Equal(t, tc.errPath, serr.Path)
+			require.Equal(t, tc.offset, serr.Offset)
+			require.Equal(t, tc.message, serr.Message)
+
+			// accepted without strict mode
+			require.NoError(t, NewStreamDecoder(strings.NewReader(tc.input)).DecodePath(tc.path, func(key []byte, message json.RawMessage) error {
+				return nil
+			}))
+		})
+	}
+}
+
+func TestDecodePathStrictValid(t *testing.T) {
+	input := `{"a": {"a": {"a": 1}}, "b": [{"a": 1}, {"a": 2}], "c": "😀 é é"} {"a": 1}`
+	var got []string
+	dec := NewStreamDecoder(strings.NewReader(input), Strict())
+	require.NoError(t, dec.DecodePath("$.b[*]", func(key []byte, message json.RawMessage) error {
+		got = append(got, string(key)+" "+string(message))
+		return nil
+	}))
+	require.Equal(t, []string{`$.b[0] {"a": 1}`, `$.b[1] {"a": 2}`}, got)
+}
@@ -0,0 +1,513 @@
+package jspath
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// A ValidationError is a value violating a keyword of the schema.
+type ValidationError struct {
+	Path string
+	// Offset is the offset of the value in the input.
+	Offset  int64
+	Keyword string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("jspath: %s at offset %d: %s: %s", e.Path, e.Offset, e.Keyword, e.Message)
+}
+
+// ValidationErrors is returned by Validate when the input does not conform to the schema.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", e[0].Error(), len(e)-1)
+}
+
+var (
+	errInvalidSchema = errors.New("jspath: invalid schema")
+	// errValidationLimit stops the decoder once MaxErrors is reached.
+	errValidationLimit = errors.New("jspath: too many validation errors")
+)
+
+// A Validator validates streams against a JSON Schema without loading them.
+//
+// It supports the type, properties, required, additionalProperties, items, enum,
+// minimum, maximum, exclusiveMinimum, exclusiveMaximum, minLength, maxLength, pattern,
+// minItems, maxItems, minProperties and maxProperties keywords of draft 2020-12,
+// and boolean schemas. The other keywords are ignored. enum only matches
+// strings, numbers, booleans and null.
+type Validator struct {
+	// MaxErrors, when positive, stops the validation once that many violations were found.
+	MaxErrors int
+	// Options are handed to the underlying StreamDecoder.
+	Options []Option
+
+	root *schemaNode
+}
+
+// NewValidator returns a Validator for the JSON Schema schema.
+func NewValidator(schema json.RawMessage) (*Validator, error) {
+	root, err := parseSchemaNode(schema)
+	if err != nil {
+		return nil, err
+	}
+	return &Validator{root: root}, nil
+}
+
+// Validate validates every document read from r. It returns ValidationErrors
+// when the documents do not conform to the schema.
+func (v *Validator) Validate(r io.Reader) error {
+	val := &validation{Validator: v, dec: NewStreamDecoder(r, v.Options...)}
+	val.dec.observer = val
+	if err := val.dec.Decode(); err != nil && err != errValidationLimit {
+		return err
+	}
+	if val.errs != nil {
+		return val.errs
+	}
+	return nil
+}
+
+const (
+	typeObject = 1 << iota
+	typeArray
+	typeString
+	typeInteger
+	typeNumber
+	typeBoolean
+	typeNull
+)
+
+var typeNames = []string{"object", "array", "string", "integer", "number", "boolean", "null"}
+
+type schemaBound struct {
+	value big.Rat
+	raw   string
+}
+
+// A schemaNode is a compiled JSON Schema.
+type schemaNode struct {
+	// reject is set for the false schema.
+	reject bool
+
+	types      int
+	properties map[string]*schemaNode
+	required   []string
+	// additional is the schema of the members not in properties, noAdditional rejects them.
+	additional   *schemaNode
+	noAdditional bool
+	items        *schemaNode
+	enum         []json.RawMessage
+
+	minimum, maximum, exclusiveMinimum, exclusiveMaximum *schemaBound
+
+	minLength, maxLength         int
+	minItems, maxItems           int
+	minProperties, maxProperties int
+	pattern                      *regexp.Regexp
+}
+
+type rawSchemaNode struct {
+	Type                 json.RawMessage            `json:"type"`
+	Properties           map[string]json.RawMessage `json:"properties"`
+	Required             []string                   `json:"required"`
+	AdditionalProperties json.RawMessage            `json:"additionalProperties"`
+	Items                json.RawMessage            `json:"items"`
+	Enum                 []json.RawMessage          `json:"enum"`
+	Minimum              json.Number                `json:"minimum"`
+	Maximum              json.Number                `json:"maximum"`
+	ExclusiveMinimum     json.Number                `json:"exclusiveMinimum"`
+	ExclusiveMaximum     json.Number                `json:"exclusiveMaximum"`
+	MinLength            *int                       `json:"minLength"`
+	MaxLength            *int                       `json:"maxLength"`
+	MinItems             *int                       `json:"minItems"`
+	MaxItems             *int                       `json:"maxItems"`
+	MinProperties        *int                       `json:"minProperties"`
+	MaxProperties        *int                       `json:"maxProperties"`
+	Pattern              *string                    `json:"pattern"`
+}
+
+// parseSchemaNode compiles schema, the true schema compiles to nil.
+func parseSchemaNode(data json.RawMessage) (*schemaNode, error) {
+	data = bytes.TrimSpace(data)
+	switch string(data) {
+	case "true":
+		return nil, nil
+	case "false":
+		return &schemaNode{reject: true}, nil
+	}
+	var raw rawSchemaNode
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidSchema, err)
+	}
+	node := &schemaNode{required: raw.Required, enum: raw.Enum}
+	if raw.Type != nil {
+		var types []string
+		if raw.Type[0] == '"' {
+			types = []string{""}
+			if err := json.Unmarshal(raw.Type, &types[0]); err != nil {
+				return nil, fmt.Errorf("%w: %v", errInvalidSchema, err)
+			}
+		} else if err := json.Unmarshal(raw.Type, &types); err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidSchema, err)
+		}
+		for _, typ := range types {
+			i := 0
+			for i < len(typeNames) && typeNames[i] != typ {
+				i++
+			}
+			if i == len(typeNames) {
+				return nil, fmt.Errorf("%w: unknown type %q", errInvalidSchema, typ)
+			}
+			node.types |= 1 << i
+		}
+	}
+	if raw.Properties != nil {
+		node.properties = make(map[string]*schemaNode, len(raw.Properties))
+		for name, property := range raw.Properties {
+			child, err := parseSchemaNode(property)
+			if err != nil {
+				return nil, err
+			}
+			node.properties[name] = child
+		}
+	}
+	var err error
+	if raw.AdditionalProperties != nil {
+		if node.additional, err = parseSchemaNode(raw.AdditionalProperties); err != nil {
+			return nil, err
+		}
+		node.noAdditional = node.additional != nil && node.additional.reject
+	}
+	if raw.Items != nil {
+		if node.items, err = parseSchemaNode(raw.Items); err != nil {
+			return nil, err
+		}
+	}
+	for _, b := range []struct {
+		raw   json.Number
+		bound **schemaBound
+	}{
+		{raw.Minimum, &node.minimum},
+		{raw.Maximum, &node.maximum},
+		{raw.ExclusiveMinimum, &node.exclusiveMinimum},
+		{raw.ExclusiveMaximum, &node.exclusiveMaximum},
+	} {
+		if b.raw == "" {
+			continue
+		}
+		bound := &schemaBound{raw: string(b.raw)}
+		if _, ok := bound.value.SetString(bound.raw); !ok {
+			return nil, fmt.Errorf("%w: invalid bound %s", errInvalidSchema, bound.raw)
+		}
+		*b.bound = bound
+	}
+	for _, l := range []struct {
+		raw   *int
+		limit *int
+		unset int
+	}{
+		{raw.MinLength, &node.minLength, 0},
+		{raw.MaxLength, &node.maxLength, -1},
+		{raw.MinItems, &node.minItems, 0},
+		{raw.MaxItems, &node.maxItems, -1},
+		{raw.MinProperties, &node.minProperties, 0},
+		{raw.MaxProperties, &node.maxProperties, -1},
+	} {
+		*l.limit = l.unset
+		if l.raw != nil {
+			*l.limit = *l.raw
+		}
+	}
+	if raw.Pattern != nil {
+		if node.pattern, err = regexp.Compile(*raw.Pattern); err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidSchema, err)
+		}
+	}
+	return node, nil
+}
+
+type validationFrame struct {
+	// node is the schema of the container, nil accepts anything.
+	node    *schemaNode
+	delim   byte
+	pathLen int
+	offset  int64
+	members int
+	// required records which required members were seen.
+	required []bool
+}
+
+// validation is the tokenObserver validating a stream.
+type validation struct {
+	*Validator
+	dec    *StreamDecoder
+	frames []validationFrame
+	errs   ValidationErrors
+}
+
+func (val *validation) openContainer(delim byte, path []byte) error {
+	offset := val.dec.offset() - 1
+	node, err := val.child(path, offset)
+	if err != nil {
+		return err
+	}
+	if node != nil {
+		typ := typeObject
+		if delim == '[' {
+			typ = typeArray
+		}
+		if node, err = val.check(node, path, offset, typ); err != nil {
+			return err
+		}
+	}
+	frame := validationFrame{node: node, delim: delim, pathLen: len(path), offset: offset}
+	if node != nil && delim == '{' && node.required != nil {
+		frame.required = make([]bool, len(node.required))
+	}
+	val.frames = append(val.frames, frame)
+	return nil
+}
+
+func (val *validation) closeContainer(delim byte) error {
+	frame := val.frames[len(val.frames)-1]
+	val.frames = val.frames[:len(val.frames)-1]
+	node := frame.node
+	if node == nil {
+		return nil
+	}
+	path := val.dec.path.PathBytes()[:frame.pathLen]
+	if delim == ']' {
+		if frame.members < node.minItems {
+			return val.report(path, frame.offset, "minItems", fmt.Sprintf("%d items, want at least %d", frame.members, node.minItems))
+		}
+		if node.maxItems >= 0 && frame.members > node.maxItems {
+			return val.report(path, frame.offset, "maxItems", fmt.Sprintf("%d items, want at most %d", frame.members, node.maxItems))
+		}
+		return nil
+	}
+	for i, seen := range frame.required {
+		if !seen {
+			if err := val.report(path, frame.offset, "required", fmt.Sprintf("missing member %q", node.required[i])); err != nil {
+				return err
+			}
+		}
+	}
+	if frame.members < node.minProperties {
+		return val.report(path, frame.offset, "minProperties", fmt.Sprintf("%d members, want at least %d", frame.members, node.minProperties))
+	}
+	if node.maxProperties >= 0 && frame.members > node.maxProperties {
+		return val.report(path, frame.offset, "maxProperties", fmt.Sprintf("%d members, want at most %d", frame.members, node.maxProperties))
+	}
+	return nil
+}
+
+func (val *validation) literal(path []byte, value []byte) error {
+	offset := val.dec.offset() - int64(len(value))
+	node, err := val.child(path, offset)
+	if err != nil || node == nil {
+		return err
+	}
+	var typ int
+	switch value[0] {
+	case '"':
+		typ = typeString
+	case 't', 'f':
+		typ = typeBoolean
+	case 'n':
+		typ = typeNull
+	default:
+		typ = typeNumber
+	}
+	if node, err = val.check(node, path, offset, typ); node == nil || err != nil {
+		return err
+	}
+	if node.enum != nil && !inEnum(node.enum, value) {
+		if err := val.report(path, offset, "enum", fmt.Sprintf("%s is not one of the allowed values", value)); err != nil {
+			return err
+		}
+	}
+	switch typ {
+	case typeString:
+		return val.checkString(node, path, offset, value)
+	case typeNumber:
+		return val.checkNumber(node, path, offset, value)
+	}
+	return nil
+}
+
+// child returns the schema of the value at path, a member or an element of the
+// innermost open container, nil when it accepts anything.
+func (val *validation) child(path []byte, offset int64) (*schemaNode, error) {
+	if len(val.frames) == 0 {
+		return val.root, nil
+	}
+	frame := &val.frames[len(val.frames)-1]
+	frame.members++
+	node := frame.node
+	if node == nil {
+		return nil, nil
+	}
+	if frame.delim == '[' {
+		return node.items, nil
+	}
+	key := path[frame.pathLen+1:]
+	for i, name := range node.required {
+		if name == BytesToString(key) {
+			frame.required[i] = true
+		}
+	}
+	if child, ok := node.properties[string(key)]; ok {
+		return child, nil
+	}
+	if node.noAdditional {
+		return nil, val.report(path, offset, "additionalProperties", fmt.Sprintf("member %q is not allowed", key))
+	}
+	return node.additional, nil
+}
+
+// check checks the value of type typ against the rejection and type of node,
+// it returns nil when the value is invalid or node accepts anything.
+func (val *validation) check(node *schemaNode, path []byte, offset int64, typ int) (*schemaNode, error) {
+	if node.reject {
+		return nil, val.report(path, offset, "false", "no value is allowed")
+	}
+	if node.types == 0 || node.types&typ != 0 || typ == typeNumber && node.types&typeInteger != 0 {
+		return node, nil
+	}
+	var want []string
+	for i, name := range typeNames {
+		if node.types&(1<<i) != 0 {
+			want = append(want, name)
+		}
+	}
+	got := typeNames[0]
+	for i := range typeNames {
+		if typ == 1<<i {
+			got = typeNames[i]
+		}
+	}
+	return nil, val.report(path, offset, "type", fmt.Sprintf("got %s, want %s", got, strings.Join(want, " or ")))
+}
+
+func (val *validation) checkString(node *schemaNode, path []byte, offset int64, value []byte) error {
+	if node.minLength == 0 && node.maxLength < 0 && node.pattern == nil {
+		return nil
+	}
+	s := unquoteString(value)
+	if n := utf8.RuneCountInString(s); n < node.minLength {
+		if err := val.report(path, offset, "minLength", fmt.Sprintf("length %d, want at least %d", n, node.minLength)); err != nil {
+			return err
+		}
+	} else if node.maxLength >= 0 && n > node.maxLength {
+		if err := val.report(path, offset, "maxLength", fmt.Sprintf("length %d, want at most %d", n, node.maxLength)); err != nil {
+			return err
+		}
+	}
+	if node.pattern != nil && !node.pattern.MatchString(s) {
+		return val.report(path, offset, "pattern", fmt.Sprintf("%s does not match %s", value, node.pattern))
+	}
+	return nil
+}
+
+func (val *validation) checkNumber(node *schemaNode, path []byte, offset int64, value []byte) error {
+	integerOnly := node.types&typeInteger != 0 && node.types&typeNumber == 0
+	if !integerOnly && node.minimum == nil && node.maximum == nil && node.exclusiveMinimum == nil && node.exclusiveMaximum == nil {
+		return nil
+	}
+	var n big.Rat
+	isInt, cmp := n.IsInt, n.Cmp
+	if _, ok := n.SetString(BytesToString(value)); !ok {
+		// the exponent is out of range, the sign and magnitude decide
+		sign, huge := numberMagnitude(BytesToString(value))
+		isInt = func() bool { return huge || sign == 0 }
+		cmp = func(bound *big.Rat) int {
+			if huge && sign != 0 || bound.Sign() == 0 {
+				return sign
+			}
+			return -bound.Sign()
+		}
+	}
+	if integerOnly && !isInt() {
+		if err := val.report(path, offset, "type", "got number, want integer"); err != nil {
+			return err
+		}
+	}
+	for _, b := range []struct {
+		keyword string
+		bound   *schemaBound
+		valid   func(cmp int) bool
+	}{
+		{"minimum", node.minimum, func(cmp int) bool { return cmp >= 0 }},
+		{"maximum", node.maximum, func(cmp int) bool { return cmp <= 0 }},
+		{"exclusiveMinimum", node.exclusiveMinimum, func(cmp int) bool { return cmp > 0 }},
+		{"exclusiveMaximum", node.exclusiveMaximum, func(cmp int) bool { return cmp < 0 }},
+	} {
+		if b.bound != nil && !b.valid(cmp(&b.bound.value)) {
+			if err := val.report(path, offset, b.keyword, fmt.Sprintf("%s is out of bound %s", value, b.bound.raw)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// numberMagnitude returns the sign of the valid JSON number s whose exponent is
+// out of range, and whether it is huge rather than tiny.
+func numberMagnitude(s string) (sign int, huge bool) {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c >= '1' && c <= '9':
+			sign = 1
+		case c == 'e' || c == 'E':
+			if sign != 0 && s[0] == '-' {
+				sign = -1
+			}
+			return sign, i+1 < len(s) && s[i+1] != '-'
+		}
+	}
+	return sign, false
+}
+
+func inEnum(enum []json.RawMessage, value []byte) bool {
+	for _, e := range enum {
+		if bytes.Equal(e, value) || jsonEqual(e, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// report records a violation, it fails once MaxErrors violations were recorded.
+func (val *validation) report(path []byte, offset int64, keyword, message string) error {
+	val.errs = append(val.errs, &ValidationError{Path: string(path), Offset: offset, Keyword: keyword, Message: message})
+	if val.MaxErrors > 0 && len(val.errs) >= val.MaxErrors {
+		return errValidationLimit
+	}
+	return nil
+}
+
+// unquoteString returns the content of the JSON string value,
+// it is only valid as long as value is.
+func unquoteString(value []byte) string {
+	if bytes.IndexByte(value, '\\') < 0 {
+		return BytesToString(value[1 : len(value)-1])
+	}
+	var s string
+	if json.Unmarshal(value, &s) != nil {
+		return BytesToString(value[1 : len(value)-1])
+	}
+	return s
+}
@@ -0,0 +1,170 @@
+package jspath
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testSchema = `{
+	"type": "object",
+	"properties": {
+		"store": {
+			"type": "object",
+			"properties": {
+				"book": {
+					"type": "array",
+					"minItems": 1,
+					"items": {
+						"type": "object",
+						"properties": {
+							"category": {"enum": ["reference", "fiction"]},
+							"title": {"type": "string", "minLength": 1},
+							"isbn": {"type": "string", "pattern": "^[0-9]-[0-9]{3}-[0-9]{5}-[0-9]$"},
+							"price": {"type": "number", "exclusiveMinimum": 0, "maximum": 100}
+						},
+						"required": ["category", "title", "price"],
+						"additionalProperties": {"type": "string"}
+					}
+				}
+			}
+		},
+		"expensive": {"type": "integer"}
+	},
+	"required": ["store"]
+}`
+
+func TestValidate(t *testing.T) {
+	var testcases = []struct {
+		name  string
+		input string
+		want  []ValidationError
+	}{
+		{
+			name:  "valid",
+			input: testdata,
+		},
+		{
+			name:  "multiple documents",
+			input: testdataMultiple,
+		},
+		{
+			name:  "type",
+			input: `{"store": [], "expensive": 1.5}`,
+			want: []ValidationError{
+				{Path: "$.store", Offset: 10, Keyword: "type", Message: "got array, want object"},
+				{Path: "$.expensive", Offset: 27, Keyword: "type", Message: "got number, want integer"},
+			},
+		},
+		{
+			name:  "required",
+			input: `{"store": {"book": [{"title": "a"}]}} {}`,
+			want: []ValidationError{
+				{Path: "$.store.book[0]", Offset: 20, Keyword: "required", Message: `missing member "category"`},
+				{Path: "$.store.book[0]", Offset: 20, Keyword: "required", Message: `missing member "price"`},
+				{Path: "$", Offset: 38, Keyword: "required", Message: `missing member "store"`},
+			},
+		},
+		{
+			name:  "literals",
+			input: `{"store": {"book": [{"category": "poetry", "title": "", "isbn": "0-x", "price": 0, "author": 1}]}}`,
+			want: []ValidationError{
+				{Path: "$.store.book[0].category", Offset: 33, Keyword: "enum", Message: `"poetry" is not one of the allowed values`},
+				{Path: "$.store.book[0].title", Offset: 52, Keyword: "minLength", Message: "length 0, want at least 1"},
+				{Path: "$.store.book[0].isbn", Offset: 64, Keyword: "pattern", Message: `"0-x" does not match ^[0-9]-[0-9]{3}-[0-9]{5}-[0-9]$`},
+				{Path: "$.store.book[0].price", Offset: 80, Keyword: "exclusiveMinimum", Message: "0 is out of bound 0"},
+				{Path: "$.store.book[0].author", Offset: 93, Keyword: "type", Message: "got number, want string"},
+			},
+		},
+		{
+			name:  "min items",
+			input: `{"store": {"book": []}}`,
+			want: []ValidationError{
+				{Path: "$.store.book", Offset: 19, Keyword: "minItems", Message: "0 items, want at least 1"},
+			},
+		},
+	}
+
+	v, err := NewValidator([]byte(testSchema))
+	require.NoError(t, err)
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := v.Validate(strings.NewReader(tc.input))
+			if tc.want == nil {
+				require.NoError(t, err)
+				return
+			}
+			var errs ValidationErrors
+			require.True(t, errors.As(err, &errs), "%v", err)
+			got := make([]ValidationError, len(errs))
+			for i := range errs {
+				got[i] = *errs[i]
+			}
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestValidateMaxErrors(t *testing.T) {
+	v, err := NewValidator([]byte(`{"items": {"type": "string"}, "additionalProperties": false}`))
+	require.NoError(t, err)
+	v.MaxErrors = 2
+	err = v.Validate(strings.NewReader(`[1, 2, 3, 4]`))
+	require.Len(t, err.(ValidationErrors), 2)
+	require.Equal(t, "jspath: $.[0] at offset 1: type: got number, want string (and 1 more errors)", err.Error())
+
+	v, err = NewValidator([]byte(`{"properties": {"a": true, "b": false}, "additionalProperties": false}`))
+	require.NoError(t, err)
+	err = v.Validate(strings.NewReader(`{"a": {"x": 1}, "b": 1, "c": [1]}`))
+	require.Len(t, err.(ValidationErrors), 2)
+	require.Equal(t, "false", err.(ValidationErrors)[0].Keyword)
+	require.Equal(t, "additionalProperties", err.(ValidationErrors)[1].Keyword)
+	require.Equal(t, "$.c", err.(ValidationErrors)[1].Path)
+}
+
+func TestNewValidatorInvalid(t *testing.T) {
+	for _, schema := range []string{`{"type": "text"}`, `{"pattern": "("}`, `{"minimum": true}`, `[]`} {
+		_, err := NewValidator([]byte(schema))
+		require.True(t, errors.Is(err, errInvalidSchema), schema)
+	}
+}
+
+func TestValidateNumberRange(t *testing.T) {
+	var testcases = []struct {
+		schema string
+		input  string
+		want   []ValidationError
+	}{
+		{
+			schema: `{"items": {"type": "integer", "minimum": -10, "maximum": 10}}`,
+			input:  `[1e300000000, -1e300000000, 1e-300000000, 0e300000000]`,
+			want: []ValidationError{
+				{Path: "$.[0]", Offset: 1, Keyword: "maximum", Message: "1e300000000 is out of bound 10"},
+				{Path: "$.[1]", Offset: 14, Keyword: "minimum", Message: "-1e300000000 is out of bound -10"},
+				{Path: "$.[2]", Offset: 28, Keyword: "type", Message: "got number, want integer"},
+			},
+		},
+		{
+			schema: `{"items": {"exclusiveMinimum": 0, "maximum": 1}}`,
+			input:  `[1e-300000000, -1e-300000000, 0E-300000000]`,
+			want: []ValidationError{
+				{Path: "$.[1]", Offset: 15, Keyword: "exclusiveMinimum", Message: "-1e-300000000 is out of bound 0"},
+				{Path: "$.[2]", Offset: 30, Keyword: "exclusiveMinimum", Message: "0E-300000000 is out of bound 0"},
+			},
+		},
+	}
+	for _, tc := range testcases {
+		v, err := NewValidator([]byte(tc.schema))
+		require.NoError(t, err)
+		err = v.Validate(strings.NewReader(tc.input))
+		var errs ValidationErrors
+		require.True(t, errors.As(err, &errs), "%v", err)
+		got := make([]ValidationError, len(errs))
+		for i := range errs {
+			got[i] = *errs[i]
+		}
+		require.Equal(t, tc.want, got)
+	}
+}
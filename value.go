@@ -0,0 +1,233 @@
+package jspath
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+)
+
+// ErrValueKind is returned by the Value accessors called on a value of another kind.
+var ErrValueKind = errors.New("jspath: value of another kind")
+
+// A Kind is the kind of a JSON value.
+type Kind int
+
+const (
+	Invalid Kind = iota
+	Null
+	Bool
+	Number
+	String
+	Object
+	Array
+)
+
+var kindNames = [...]string{"invalid", "null", "boolean", "number", "string", "object", "array"}
+
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return "Kind(" + strconv.Itoa(int(k)) + ")"
+	}
+	return kindNames[k]
+}
+
+// A Value is a view of a raw JSON value, like the messages handed over to the
+// handlers, read in place without unmarshaling it:
+//
+//	price, err := jspath.Value(message).Get("offers[0].price").Float()
+//
+// The accessors parse lazily and do not allocate, except Str for the string it
+// returns and Get for long escaped member names. A Value is only valid as long
+// as the bytes it views. It is assumed to hold valid JSON, as the matched values
+// do, accessors on anything else return ErrValueKind or meaningless results but
+// never panic.
+type Value []byte
+
+func (v Value) trimmed() Value {
+	i := skipSpace(v, 0)
+	return v[i : i+valueLen(v[i:])]
+}
+
+// Kind returns the kind of v, Invalid for a missing value.
+func (v Value) Kind() Kind {
+	i := skipSpace(v, 0)
+	if i == len(v) {
+		return Invalid
+	}
+	switch c := v[i]; {
+	case c == 'n':
+		return Null
+	case c == 't' || c == 'f':
+		return Bool
+	case c == '"':
+		return String
+	case c == '{':
+		return Object
+	case c == '[':
+		return Array
+	case c == '-' || '0' <= c && c <= '9':
+		return Number
+	}
+	return Invalid
+}
+
+// Get returns the value at the path relative to v, made of member names and
+// array indices, like "a.b[2].c". A leading $ is ignored. Get returns a nil
+// Value when there is no value at the path.
+func (v Value) Get(path string) Value {
+	if len(path) > 0 && path[0] == '$' {
+		path = path[1:]
+	}
+	v = v.trimmed()
+	for len(path) > 0 && v != nil {
+		switch path[0] {
+		case '.':
+			path = path[1:]
+		case '[':
+			end := 1
+			for end < len(path) && path[end] != ']' {
+				end++
+			}
+			index, err := strconv.Atoi(path[1:end])
+			if err != nil || end == len(path) {
+				return nil
+			}
+			v = v.index(index)
+			path = path[end+1:]
+		default:
+			end := 0
+			for end < len(path) && path[end] != '.' && path[end] != '[' {
+				end++
+			}
+			v = v.member(path[:end])
+			path = path[end:]
+		}
+	}
+	return v
+}
+
+func (v Value) index(index int) Value {
+	if v.Kind() != Array {
+		return nil
+	}
+	for i := skipSpace(v, 1); i >= 0; index-- {
+		_, value, next := v.entry(i, Array)
+		if value == nil || index == 0 {
+			return value
+		}
+		i = next
+	}
+	return nil
+}
+
+func (v Value) member(name string) Value {
+	if v.Kind() != Object {
+		return nil
+	}
+	for i := skipSpace(v, 1); i >= 0; {
+		key, value, next := v.entry(i, Object)
+		if value == nil {
+			return nil
+		}
+		if key = key[1 : len(key)-1]; BytesToString(key) == name || bytes.IndexByte(key, '\\') >= 0 && unescapedEqual(key, name) {
+			return value
+		}
+		i = next
+	}
+	return nil
+}
+
+func unescapedEqual(key []byte, name string) bool {
+	var buf [64]byte
+	return string(appendUnescaped(buf[:0], key)) == name
+}
+
+// entry returns the key, nil for arrays, and the value of the member or element
+// at v[i:], and the index of the next one, -1 after the last. value is nil past the end.
+func (v Value) entry(i int, kind Kind) (key, value Value, next int) {
+	if i >= len(v) || v[i] == '}' || v[i] == ']' {
+		return nil, nil, -1
+	}
+	if kind == Object {
+		end := i + valueLen(v[i:])
+		if end-i < 2 || v[i] != '"' {
+			return nil, nil, -1
+		}
+		key = v[i:end]
+		if i = skipSpace(v, end); i < len(v) && v[i] == ':' {
+			i = skipSpace(v, i+1)
+		}
+	}
+	end := i + valueLen(v[i:])
+	value = v[i:end]
+	next = skipSpace(v, end)
+	if next < len(v) && v[next] == ',' {
+		return key, value, skipSpace(v, next+1)
+	}
+	return key, value, -1
+}
+
+// Int returns the integer value of v.
+func (v Value) Int() (int64, error) {
+	v = v.trimmed()
+	if v.Kind() != Number {
+		return 0, ErrValueKind
+	}
+	return strconv.ParseInt(BytesToString(v), 10, 64)
+}
+
+// Float returns the number value of v.
+func (v Value) Float() (float64, error) {
+	v = v.trimmed()
+	if v.Kind() != Number {
+		return 0, ErrValueKind
+	}
+	return strconv.ParseFloat(BytesToString(v), 64)
+}
+
+// Bool returns the boolean value of v.
+func (v Value) Bool() (bool, error) {
+	switch v = v.trimmed(); string(v) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	return false, ErrValueKind
+}
+
+// Str returns the unescaped string value of v.
+func (v Value) Str() (string, error) {
+	v = v.trimmed()
+	if v.Kind() != String || len(v) < 2 {
+		return "", ErrValueKind
+	}
+	content := v[1 : len(v)-1]
+	if bytes.IndexByte(content, '\\') < 0 {
+		return string(content), nil
+	}
+	var buf [64]byte
+	return string(appendUnescaped(buf[:0], content)), nil
+}
+
+// ForEach calls fn with the members of the object v, key being the quoted
+// member name, or with the elements of the array v and a nil key. It stops at
+// the first error returned by fn and returns it.
+func (v Value) ForEach(fn func(key, value Value) error) error {
+	v = v.trimmed()
+	kind := v.Kind()
+	if kind != Object && kind != Array {
+		return ErrValueKind
+	}
+	for i := skipSpace(v, 1); i >= 0; {
+		key, value, next := v.entry(i, kind)
+		if value == nil {
+			return nil
+		}
+		if err := fn(key, value); err != nil {
+			return err
+		}
+		i = next
+	}
+	return nil
+}
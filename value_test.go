@@ -0,0 +1,146 @@
+package jspath
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testValue = Value(` {"name": "Sayings \"of\" the Century", "price": 8.95, "count": 12, "tags": ["a", {"b": [true, null]}],
+	"available": false, "escaped": "é", "nested": {"a": {"b": [1, 2, 3]}}} `)
+
+func TestValueGet(t *testing.T) {
+	var testcases = []struct {
+		path string
+		want string
+		kind Kind
+	}{
+		{path: "", want: strings.TrimSpace(string(testValue)), kind: Object},
+		{path: "$", want: strings.TrimSpace(string(testValue)), kind: Object},
+		{path: "name", want: `"Sayings \"of\" the Century"`, kind: String},
+		{path: "$.price", want: `8.95`, kind: Number},
+		{path: "tags[0]", want: `"a"`, kind: String},
+		{path: "tags[1].b", want: `[true, null]`, kind: Array},
+		{path: "tags[1].b[1]", want: `null`, kind: Null},
+		{path: "available", want: `false`, kind: Bool},
+		{path: "escaped", want: `"é"`, kind: String},
+		{path: "nested.a.b[2]", want: `3`, kind: Number},
+		{path: "nested.a.b[3]", kind: Invalid},
+		{path: "missing", kind: Invalid},
+		{path: "name.first", kind: Invalid},
+		{path: "tags[x]", kind: Invalid},
+		{path: "tags[1", kind: Invalid},
+	}
+	for _, tc := range testcases {
+		got := testValue.Get(tc.path)
+		require.Equal(t, tc.want, string(got), tc.path)
+		require.Equal(t, tc.kind, got.Kind(), tc.path)
+	}
+}
+
+func TestValueAccessors(t *testing.T) {
+	s, err := testValue.Get("name").Str()
+	require.NoError(t, err)
+	require.Equal(t, `Sayings "of" the Century`, s)
+	s, err = testValue.Get("escaped").Str()
+	require.NoError(t, err)
+	require.Equal(t, "é", s)
+
+	f, err := testValue.Get("price").Float()
+	require.NoError(t, err)
+	require.Equal(t, 8.95, f)
+	n, err := testValue.Get("count").Int()
+	require.NoError(t, err)
+	require.Equal(t, int64(12), n)
+	_, err = testValue.Get("price").Int()
+	require.Error(t, err)
+
+	b, err := testValue.Get("tags[1].b[0]").Bool()
+	require.NoError(t, err)
+	require.True(t, b)
+
+	for _, fn := range []func() error{
+		func() error { _, err := testValue.Get("name").Int(); return err },
+		func() error { _, err := testValue.Get("count").Bool(); return err },
+		func() error { _, err := testValue.Get("count").Str(); return err },
+		func() error { _, err := testValue.Get("missing").Float(); return err },
+		func() error { return testValue.Get("count").ForEach(nil) },
+	} {
+		require.True(t, errors.Is(fn(), ErrValueKind))
+	}
+}
+
+func TestValueForEach(t *testing.T) {
+	var got []string
+	require.NoError(t, testValue.ForEach(func(key, value Value) error {
+		name, err := key.Str()
+		got = append(got, name+"="+value.Kind().String())
+		return err
+	}))
+	require.Equal(t, []string{"name=string", "price=number", "count=number", "tags=array", "available=boolean", "escaped=string", "nested=object"}, got)
+
+	got = got[0:0]
+	require.NoError(t, testValue.Get("tags").ForEach(func(key, value Value) error {
+		require.Nil(t, key)
+		got = append(got, string(value))
+		return nil
+	}))
+	require.Equal(t, []string{`"a"`, `{"b": [true, null]}`}, got)
+
+	errStop := errors.New("stop")
+	require.Equal(t, errStop, testValue.ForEach(func(key, value Value) error {
+		return errStop
+	}))
+	require.NoError(t, Value(`[]`).ForEach(func(key, value Value) error {
+		return errStop
+	}))
+	require.NoError(t, Value(`{ }`).ForEach(func(key, value Value) error {
+		return errStop
+	}))
+}
+
+func TestValueInHandler(t *testing.T) {
+	input := `{"offers": [{"price": 10, "seller": {"name": "a"}}, {"price": 12.5, "seller": {"name": "b"}}]}`
+	var total float64
+	var sellers []string
+	require.NoError(t, NewStreamDecoder(strings.NewReader(input)).DecodePath("$.offers[*]", func(key []byte, message json.RawMessage) error {
+		price, err := Value(message).Get("price").Float()
+		if err != nil {
+			return err
+		}
+		total += price
+		name, err := Value(message).Get("seller.name").Str()
+		sellers = append(sellers, name)
+		return err
+	}))
+	require.Equal(t, 22.5, total)
+	require.Equal(t, []string{"a", "b"}, sellers)
+}
+
+func TestValueInvalid(t *testing.T) {
+	for _, input := range []string{``, `{`, `[1,`, `{"a"`, `{"a":`, `"abc`, `{"a" 1}`, `[}`, `{1: 2}`, `}`} {
+		v := Value(input)
+		v.Kind()
+		v.Get("a.b[1]")
+		v.Str()
+		v.ForEach(func(key, value Value) error {
+			return nil
+		})
+	}
+}
+
+func TestValueAllocs(t *testing.T) {
+	allocs := testing.AllocsPerRun(100, func() {
+		testValue.Get("nested.a.b[2]").Int()
+		testValue.Get("price").Float()
+		testValue.Get("tags[1].b[0]").Bool()
+		testValue.Get("escaped").Kind()
+		testValue.ForEach(func(key, value Value) error {
+			return nil
+		})
+	})
+	require.Equal(t, float64(0), allocs)
+}
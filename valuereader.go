@@ -0,0 +1,126 @@
+package jspath
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// A ReaderUnmarshalerStream is an UnmarshalerStream receiving the matched values
+// of a JSON stream as readers, without buffering them whole. UnmarshalStream is
+// still called for the inputs that are buffered anyway, like the binary formats.
+type ReaderUnmarshalerStream interface {
+	UnmarshalerStream
+	// UnmarshalStreamReader is called once the path is matched, value streams the
+	// JSON encoding of the matched value. The decoder resumes after the handler
+	// returns, the part of value left unread is skipped.
+	UnmarshalStreamReader(key []byte, value io.Reader) error
+}
+
+// DecodePathReader is like DecodePath, but streams the matched values out of the
+// decoder instead of buffering them, so their size is not bounded by memory.
+// The strict mode and the structural limits do not apply to streamed values.
+func (dec *StreamDecoder) DecodePathReader(jsPath string, onPath func(key []byte, value io.Reader) error) error {
+	d, err := dec.newDecoder(&readerStreamUnmarshaler{matchPath: jsPath, onMatch: onPath})
+	if err != nil {
+		return err
+	}
+	go dec.decode(d)
+	<-dec.Done()
+	return dec.err
+}
+
+type readerStreamUnmarshaler struct {
+	matchPath string
+	onMatch   func(key []byte, value io.Reader) error
+}
+
+func (r *readerStreamUnmarshaler) AtPath() string {
+	return r.matchPath
+}
+
+func (r *readerStreamUnmarshaler) UnmarshalStream(key []byte, message json.RawMessage) error {
+	return r.onMatch(key, bytes.NewReader(message))
+}
+
+func (r *readerStreamUnmarshaler) UnmarshalStreamReader(key []byte, value io.Reader) error {
+	return r.onMatch(key, value)
+}
+
+// handleReader streams the value at dec.scanp to its unmarshaler and, once it
+// has been accepted, reports a checkpoint positioned right after it.
+func (dec *StreamDecoder) handleReader(d ReaderUnmarshalerStream, key []byte) error {
+	if err := dec.tokenPrepareForDecode(); err != nil {
+		return err
+	}
+	dec.scan.reset()
+	vr := &valueReader{dec: dec}
+	if err := d.UnmarshalStreamReader(key, vr); err != nil {
+		return err
+	}
+	if _, err := io.Copy(io.Discard, vr); err != nil {
+		return err
+	}
+	dec.tokenValueEnd()
+	if dec.onCheckpoint != nil {
+		return dec.onCheckpoint(dec.checkpoint())
+	}
+	return nil
+}
+
+// valueReader reads the value at dec.scanp through the decoder buffer, which
+// is refilled in place as the value is consumed.
+type valueReader struct {
+	dec     *StreamDecoder
+	started bool
+	done    bool
+	err     error
+}
+
+func (vr *valueReader) Read(p []byte) (int, error) {
+	dec := vr.dec
+	for !vr.done && dec.scanp == len(dec.buf) {
+		if vr.err != nil {
+			// a number ends with the input
+			if vr.err == io.EOF && dec.scan.step(&dec.scan, ' ') == scanEnd {
+				vr.done = true
+				break
+			}
+			if vr.err == io.EOF {
+				vr.err = io.ErrUnexpectedEOF
+			}
+			dec.err = vr.err
+			return 0, vr.err
+		}
+		vr.err = dec.refill()
+	}
+	n := 0
+	for !vr.done && n < len(p) && dec.scanp < len(dec.buf) {
+		c := dec.buf[dec.scanp]
+		dec.scan.bytes++
+		v := dec.scan.step(&dec.scan, c)
+		if v == scanEnd {
+			// c follows the value
+			vr.done = true
+			break
+		}
+		if v == scanError {
+			dec.err = dec.scan.err
+			return n, dec.scan.err
+		}
+		dec.scanp++
+		if v == scanSkipSpace && !vr.started {
+			continue
+		}
+		vr.started = true
+		p[n] = c
+		n++
+		if (v == scanEndObject || v == scanEndArray) && dec.scan.step(&dec.scan, ' ') == scanEnd {
+			vr.done = true
+		}
+	}
+	if n == 0 && vr.done {
+		return 0, io.EOF
+	}
+	return n, nil
+}
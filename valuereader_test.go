@@ -0,0 +1,113 @@
+package jspath
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodePathReader(t *testing.T) {
+	var testcases = []struct {
+		name  string
+		input string
+		path  string
+		want  []string
+	}{
+		{
+			name:  "values",
+			input: `{"a": [1, "two", {"three": [3, 3]}, [4], true, null]}`,
+			path:  "$.a[*]",
+			want:  []string{`$.a[0] 1`, `$.a[1] "two"`, `$.a[2] {"three": [3, 3]}`, `$.a[3] [4]`, `$.a[4] true`, `$.a[5] null`},
+		},
+		{
+			name:  "top level values",
+			input: ` {"a": 1} [2] "3" 4`,
+			path:  "$",
+			want:  []string{`$ {"a": 1}`, `$ [2]`, `$ "3"`, `$ 4`},
+		},
+		{
+			name:  "escaped quotes",
+			input: `{"a": "x\"}y", "b": 1}`,
+			path:  "$.*",
+			want:  []string{`$.a "x\"}y"`, `$.b 1`},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			dec := NewStreamDecoder(iotest.OneByteReader(strings.NewReader(tc.input)))
+			require.NoError(t, dec.DecodePathReader(tc.path, func(key []byte, value io.Reader) error {
+				data, err := io.ReadAll(iotest.OneByteReader(value))
+				require.NoError(t, err)
+				got = append(got, string(key)+" "+string(data))
+				return nil
+			}))
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestDecodePathReaderLargeValue(t *testing.T) {
+	large := strings.Repeat("0123456789", 1<<17)
+	input := `{"attachments": [{"name": "a", "data": "` + large + `"}, {"name": "b", "data": "small"}]}`
+	var names []string
+	var sizes []int64
+	dec := NewStreamDecoder(strings.NewReader(input))
+	require.NoError(t, dec.DecodePathReader("$.attachments[*].*", func(key []byte, value io.Reader) error {
+		if strings.HasSuffix(string(key), ".name") {
+			data, err := io.ReadAll(value)
+			names = append(names, string(data))
+			return err
+		}
+		n, err := io.Copy(io.Discard, value)
+		sizes = append(sizes, n)
+		return err
+	}))
+	require.Equal(t, []string{`"a"`, `"b"`}, names)
+	require.Equal(t, []int64{int64(len(large) + 2), 7}, sizes)
+	require.Less(t, cap(dec.buf), 1<<16)
+}
+
+func TestDecodePathReaderPartial(t *testing.T) {
+	input := `[{"a": "` + strings.Repeat("x", 10000) + `"}, {"a": "y"}]`
+	var got []string
+	require.NoError(t, NewStreamDecoder(strings.NewReader(input)).DecodePathReader("$.[*].a", func(key []byte, value io.Reader) error {
+		data := make([]byte, 4)
+		n, err := io.ReadFull(value, data)
+		got = append(got, string(key)+" "+string(data[:n]))
+		if err == io.ErrUnexpectedEOF {
+			err = nil
+		}
+		return err
+	}))
+	require.Equal(t, []string{`$.[0].a "xxx`, `$.[1].a "y"`}, got)
+
+	errStop := errors.New("stop")
+	err := NewStreamDecoder(strings.NewReader(input)).DecodePathReader("$.[*].a", func(key []byte, value io.Reader) error {
+		return errStop
+	})
+	require.Equal(t, errStop, err)
+
+	err = NewStreamDecoder(strings.NewReader(`{"a": "unterminated`)).DecodePathReader("$.a", func(key []byte, value io.Reader) error {
+		_, err := io.ReadAll(value)
+		return err
+	})
+	require.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+func TestDecodePathReaderBinary(t *testing.T) {
+	var got []string
+	dec := NewStreamDecoder(bytes.NewReader(decodeHex(t, testMsgpack)), MessagePack(false))
+	require.NoError(t, dec.DecodePathReader("$.store.book[*].title", func(key []byte, value io.Reader) error {
+		data, err := io.ReadAll(value)
+		got = append(got, string(key)+" "+string(data))
+		return err
+	}))
+	require.Equal(t, []string{`$.store.book[0].title "a"`, `$.store.book[1].title "b"`}, got)
+}